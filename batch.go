@@ -0,0 +1,73 @@
+package gogosseract
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/danlock/pkg/errors"
+)
+
+// BatchOptions configures ParseImages.
+type BatchOptions struct {
+	// ParseImageOptions applies to every image. ProgressCB gets the average progress of the whole batch.
+	ParseImageOptions
+	// Concurrency caps how many of the batch's images are parsed at once, so one batch can't monopolize the Pool.
+	// Defaults to half of the Pool's workers, or 1.
+	Concurrency uint
+}
+
+// ImageResult is the outcome of parsing one of ParseImages' images.
+type ImageResult struct {
+	// Index is the image's position within the images given to ParseImages.
+	Index int
+	Text  string
+	Err   error
+}
+
+// ParseImages parses every image like ParseImage, sending each result as soon as it's finished.
+// The returned channel is closed once every image has a result. It's buffered for every image,
+// so giving up on it early doesn't leak goroutines, although the remaining images are still parsed unless ctx is canceled.
+func (p *Pool) ParseImages(ctx context.Context, imgs []io.Reader, opts BatchOptions) <-chan ImageResult {
+	concurrency := opts.Concurrency
+	if concurrency == 0 {
+		concurrency = max(1, p.capacity()/2)
+	}
+	results := make(chan ImageResult, len(imgs))
+	done, err := p.track(uint(len(imgs)))
+	if err != nil {
+		for i := range imgs {
+			results <- ImageResult{Index: i, Err: errors.Wrap(err)}
+		}
+		close(results)
+		return results
+	}
+	progress := averageProgress{percents: make([]int32, len(imgs)), cb: opts.ProgressCB}
+	limit := make(chan struct{}, concurrency)
+
+	go func() {
+		defer close(results)
+		var wg sync.WaitGroup
+		for i, img := range imgs {
+			select {
+			case <-ctx.Done():
+				results <- ImageResult{Index: i, Err: errors.Errorf("while waiting for the batch %w", context.Cause(ctx))}
+				done()
+				continue
+			case limit <- struct{}{}:
+			}
+			wg.Add(1)
+			go func(i int, img io.Reader) {
+				defer wg.Done()
+				defer done()
+				defer func() { <-limit }()
+				imgOpts := opts.ParseImageOptions
+				imgOpts.ProgressCB = progress.callback(i)
+				text, err := p.parseImage(ctx, img, imgOpts)
+				results <- ImageResult{Index: i, Text: text, Err: err}
+			}(i, img)
+		}
+		wg.Wait()
+	}()
+	return results
+}
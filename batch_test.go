@@ -0,0 +1,52 @@
+package gogosseract_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/danlock/gogosseract"
+	"github.com/danlock/pkg/test"
+)
+
+func TestPool_ParseImages(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := gogosseract.NewPool(ctx, 3, gogosseract.PoolConfig{TrainingDataBytes: engTrainedData})
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	imgs := []io.Reader{
+		bytes.NewBuffer(docsImg), bytes.NewBuffer(logoImg), nil,
+		bytes.NewBuffer(logoImg), bytes.NewBuffer(docsImg),
+	}
+	var lastProgress int32
+	results := pool.ParseImages(ctx, imgs, gogosseract.BatchOptions{
+		ParseImageOptions: gogosseract.ParseImageOptions{ProgressCB: func(p int32) { lastProgress = p }},
+		Concurrency:       2,
+	})
+
+	seen := make(map[int]bool)
+	for r := range results {
+		if seen[r.Index] {
+			t.Fatalf("ParseImages returned image %d twice", r.Index)
+		}
+		seen[r.Index] = true
+		switch {
+		case imgs[r.Index] == nil:
+			if r.Err == nil {
+				t.Fatalf("ParseImages should have failed on the nil image")
+			}
+		case r.Err != nil:
+			t.Fatalf("ParseImages failed on image %d due to %v", r.Index, r.Err)
+		case r.Text != logoText && r.Text != docsText:
+			t.Fatalf("ParseImages returned unexpected text %s", r.Text)
+		}
+	}
+	if len(seen) != len(imgs) || lastProgress == 0 {
+		t.Fatalf("ParseImages returned %d of %d results, with %d%% progress", len(seen), len(imgs), lastProgress)
+	}
+}
@@ -0,0 +1,143 @@
+package gogosseract
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/danlock/pkg/errors"
+)
+
+// ResultCache stores recognized text, so an image parsed before doesn't need recognizing again.
+// Keys are hex encoded hashes of the image, the training data, Config.Language, the variables and the output options.
+// Set it with Config.ResultCache. Methods are called from many goroutines at once.
+type ResultCache interface {
+	// Get returns the text stored under key, and whether there was any.
+	Get(key string) (text string, ok bool)
+	// Set stores text under key.
+	Set(key, text string)
+}
+
+// optionsKey describes every option that changes a worker's response, with variables sorted so their order doesn't matter.
+func optionsKey(lang string, isHOCR bool, loadOpts LoadImageOptions, layout bool, variables map[string]string) string {
+	var key strings.Builder
+	fmt.Fprintf(&key, "%q %t %t %t", lang, isHOCR, loadOpts.RemoveUnderlines, layout)
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(&key, " %q=%q", name, variables[name])
+	}
+	return key.String()
+}
+
+// resultKey is the ResultCache key for an image's text, recognized by the training data modelID with opts.
+func resultKey(img [sha256.Size]byte, modelID string, opts string) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%x %s %s", img, modelID, opts)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// trainingDataID hashes the training data to tell models apart in ResultCache keys, without using it up.
+func trainingDataID(ctx context.Context, readerPtr *io.Reader) (string, error) {
+	sum, _, err := hashReader(ctx, readerPtr)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// LRUCache is an in memory ResultCache that forgets the least recently used text once it's full.
+type LRUCache struct {
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+	mu         sync.Mutex
+}
+
+type lruEntry struct {
+	key, text string
+}
+
+var _ ResultCache = (*LRUCache)(nil)
+
+// NewLRUCache creates an LRUCache that holds up to maxEntries texts.
+func NewLRUCache(maxEntries int) *LRUCache {
+	return &LRUCache{maxEntries: max(1, maxEntries), entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (c *LRUCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry).text, true
+}
+
+func (c *LRUCache) Set(key, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*lruEntry).text = text
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, text: text})
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// DirCache is a ResultCache that stores each text as a file within a directory, so it outlives the process.
+// It never deletes anything, so clean out the directory as you see fit. Failing to read or write a file counts as a miss.
+type DirCache struct {
+	dir string
+}
+
+var _ ResultCache = (*DirCache)(nil)
+
+// NewDirCache creates a DirCache within dir, creating the directory if needed.
+func NewDirCache(dir string) (*DirCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Errorf("os.MkdirAll %w", err)
+	}
+	return &DirCache{dir: dir}, nil
+}
+
+func (c *DirCache) Get(key string) (string, bool) {
+	text, err := os.ReadFile(filepath.Join(c.dir, key))
+	if err != nil {
+		return "", false
+	}
+	return string(text), true
+}
+
+func (c *DirCache) Set(key, text string) {
+	// Write to a temporary file first so a concurrent Get never sees partial text.
+	tmp, err := os.CreateTemp(c.dir, key+".tmp*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.WriteString(text)
+	if err = errors.Join(err, tmp.Close()); err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(c.dir, key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,70 @@
+package gogosseract
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLRUCache(t *testing.T) {
+	cache := NewLRUCache(2)
+	cache.Set("a", "1")
+	cache.Set("b", "2")
+	// Using a makes b the least recently used.
+	if text, ok := cache.Get("a"); !ok || text != "1" {
+		t.Fatalf("LRUCache.Get returned %s, %t", text, ok)
+	}
+	cache.Set("c", "3")
+	if _, ok := cache.Get("b"); ok {
+		t.Fatalf("LRUCache kept the least recently used text")
+	}
+	cache.Set("a", "4")
+	for key, want := range map[string]string{"a": "4", "c": "3"} {
+		if text, ok := cache.Get(key); !ok || text != want {
+			t.Fatalf("LRUCache.Get %s returned %s, %t", key, text, ok)
+		}
+	}
+}
+
+func TestDirCache(t *testing.T) {
+	dir := t.TempDir() + "/results"
+	cache, err := NewDirCache(dir)
+	if err != nil {
+		t.Fatalf("NewDirCache failed due to %v", err)
+	}
+	if _, ok := cache.Get("a"); ok {
+		t.Fatalf("DirCache.Get found a text that was never set")
+	}
+	cache.Set("a", "1")
+	cache.Set("a", "2")
+
+	// A new DirCache over the same directory sees the same texts.
+	cache, err = NewDirCache(dir)
+	if err != nil {
+		t.Fatalf("NewDirCache failed due to %v", err)
+	}
+	if text, ok := cache.Get("a"); !ok || text != "2" {
+		t.Fatalf("DirCache.Get returned %s, %t", text, ok)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("DirCache left %d files behind instead of 1", len(files))
+	}
+}
+
+func TestResultKey(t *testing.T) {
+	opts := optionsKey("eng", false, LoadImageOptions{}, false, map[string]string{"a": "1"})
+	key := resultKey([32]byte{1}, "model", opts)
+	if key != resultKey([32]byte{1}, "model", opts) {
+		t.Fatalf("resultKey isn't deterministic")
+	}
+	for _, other := range []string{
+		resultKey([32]byte{2}, "model", opts),
+		resultKey([32]byte{1}, "other model", opts),
+		resultKey([32]byte{1}, "model", optionsKey("fra", false, LoadImageOptions{}, false, map[string]string{"a": "1"})),
+		resultKey([32]byte{1}, "model", optionsKey("eng", true, LoadImageOptions{}, false, map[string]string{"a": "1"})),
+		resultKey([32]byte{1}, "model", optionsKey("eng", false, LoadImageOptions{}, false, map[string]string{"a": "2"})),
+	} {
+		if other == key {
+			t.Fatalf("resultKey matched for different inputs")
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package gogosseract
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+
+	"github.com/danlock/pkg/errors"
+)
+
+// dedupKey identifies requests that would get the same response from a worker.
+type dedupKey struct {
+	img [sha256.Size]byte
+	// opts holds every option that changes the response.
+	opts string
+	// priority keeps a request from waiting on an identical one queued behind higher priority requests.
+	priority int
+	// tenant keeps a request from sharing another tenant's response, which counts against that tenant's share instead.
+	tenant string
+}
+
+// inflightCall is a request that identical requests wait on instead of sending their own.
+type inflightCall struct {
+	done chan struct{}
+	resp workerResp
+	// canceled means the request's own context ended it, so its response is no use to anyone else.
+	canceled bool
+}
+
+// sendDeduplicated sends req, unless an identical request is already in flight, in which case it shares that response.
+func (p *Pool) sendDeduplicated(key dedupKey, req workerReq) workerResp {
+	for {
+		p.inflightMu.Lock()
+		call, ok := p.inflight[key]
+		if !ok {
+			call = &inflightCall{done: make(chan struct{})}
+			p.inflight[key] = call
+			p.inflightMu.Unlock()
+
+			call.resp = p.route(req)
+			call.canceled = call.resp.err != nil && req.ctx.Err() != nil
+			p.inflightMu.Lock()
+			delete(p.inflight, key)
+			p.inflightMu.Unlock()
+			close(call.done)
+			return call.resp
+		}
+		p.inflightMu.Unlock()
+
+		select {
+		case <-req.ctx.Done():
+			resp := workerResp{err: errors.Errorf("while waiting for an identical request %w", context.Cause(req.ctx))}
+			p.metrics.RequestDone(resp.err)
+			return resp
+		case <-call.done:
+		}
+		if !call.canceled {
+			p.metrics.RequestDone(call.resp.err)
+			return call.resp
+		}
+		// The request we waited on was canceled by its caller, so try again with our own.
+	}
+}
+
+// dedupKey identifies req by its image's hash and options.
+func (p *Pool) dedupKey(img [sha256.Size]byte, req workerReq) dedupKey {
+	lang := req.opts.Language
+	if lang == "" {
+		lang = p.cfg.Language
+	}
+	return dedupKey{
+		img:      img,
+		opts:     optionsKey(lang, req.opts.IsHOCR, req.opts.LoadImageOptions, req.layout, req.opts.Variables),
+		priority: req.opts.Priority,
+		tenant:   req.opts.TenantID,
+	}
+}
+
+// hashReader hashes the rest of the reader without using it up, leaving *readerPtr where it was.
+// rewind is from rewindable, for reading it again later without buffering it twice.
+func hashReader(ctx context.Context, readerPtr *io.Reader) (sum [sha256.Size]byte, rewind func() (io.Reader, error), err error) {
+	if rewind, err = rewindable(ctx, readerPtr); err != nil {
+		return sum, nil, errors.Wrap(err)
+	}
+	src, err := rewind()
+	if err != nil {
+		return sum, nil, errors.Wrap(err)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, src); err != nil {
+		return sum, nil, errors.Errorf("io.Copy %w", err)
+	}
+	if *readerPtr, err = rewind(); err != nil {
+		return sum, nil, errors.Wrap(err)
+	}
+	hash.Sum(sum[:0])
+	return sum, rewind, nil
+}
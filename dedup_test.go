@@ -0,0 +1,82 @@
+package gogosseract
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestHashReader(t *testing.T) {
+	ctx := context.Background()
+	const content = "not really an image"
+	partlyRead := bytes.NewReader([]byte("xx" + content))
+	partlyRead.Seek(2, io.SeekStart)
+
+	var buffered io.Reader = bytes.NewBufferString(content)
+	want, _, err := hashReader(ctx, &buffered)
+	if err != nil {
+		t.Fatalf("hashReader failed due to %v", err)
+	}
+	for name, reader := range map[string]io.Reader{
+		"strings.Reader":   strings.NewReader(content),
+		"partly read":      partlyRead,
+		"can't read twice": io.MultiReader(strings.NewReader(content)),
+		"unsized seekable": struct{ io.ReadSeeker }{strings.NewReader(content)},
+	} {
+		got, rewind, err := hashReader(ctx, &reader)
+		if err != nil {
+			t.Fatalf("hashReader %s failed due to %v", name, err)
+		}
+		if got != want {
+			t.Fatalf("hashReader %s returned a different hash", name)
+		}
+		if rest, _ := io.ReadAll(reader); string(rest) != content {
+			t.Fatalf("hashReader %s used up the reader, leaving %q", name, rest)
+		}
+		if again, err := rewind(); err != nil {
+			t.Fatalf("hashReader %s rewind failed due to %v", name, err)
+		} else if rest, _ := io.ReadAll(again); string(rest) != content {
+			t.Fatalf("hashReader %s rewind read %q", name, rest)
+		}
+	}
+
+	var empty io.Reader = strings.NewReader("")
+	if _, _, err := hashReader(ctx, &empty); err == nil {
+		t.Fatalf("hashReader should have failed on an empty reader")
+	}
+}
+
+func TestPool_dedupKey(t *testing.T) {
+	p := &Pool{cfg: PoolConfig{Config: Config{Language: "eng"}}}
+	key := func(opts ParseImageOptions) dedupKey {
+		return p.dedupKey([sha256.Size]byte{1}, workerReq{opts: opts})
+	}
+
+	base := key(ParseImageOptions{Variables: map[string]string{"a": "1", "b": "2"}})
+	same := []ParseImageOptions{
+		{Variables: map[string]string{"b": "2", "a": "1"}, Language: "eng", ProgressCB: func(int32) {}},
+	}
+	for _, opts := range same {
+		if key(opts) != base {
+			t.Fatalf("dedupKey differed for %+v", opts)
+		}
+	}
+	different := []ParseImageOptions{
+		{},
+		{Variables: map[string]string{"a": "1", "b": "3"}},
+		{Variables: map[string]string{"a": "1", "b": "2"}, IsHOCR: true},
+		{Variables: map[string]string{"a": "1", "b": "2"}, Language: "fra"},
+		{Variables: map[string]string{"a": "1", "b": "2"}, LoadImageOptions: LoadImageOptions{RemoveUnderlines: true}},
+		{Variables: map[string]string{"a": "1 \"b\"=\"2\""}},
+		{Variables: map[string]string{"a": "1", "b": "2"}, Priority: 5},
+		{Variables: map[string]string{"a": "1", "b": "2"}, TenantID: "other"},
+	}
+	for _, opts := range different {
+		if key(opts) == base {
+			t.Fatalf("dedupKey matched for %+v", opts)
+		}
+	}
+}
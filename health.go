@@ -0,0 +1,116 @@
+package gogosseract
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// maxFailures is how many of the most recent worker failures PoolHealth keeps.
+	maxFailures = 10
+	// maxRestartDelay caps how long replaceWorker backs off between attempts.
+	maxRestartDelay = time.Minute
+)
+
+// PoolHealth reports on the state of a Pool's workers.
+type PoolHealth struct {
+	// Live is how many workers are serving requests.
+	Live uint
+	// Busy is how many of the Live workers are parsing an image right now.
+	Busy uint
+	// Failed counts every worker that crashed, or failed to start up as a replacement, since NewPool.
+	Failed uint
+	// Failures holds the most recent failures, oldest first.
+	Failures []WorkerFailure
+}
+
+// WorkerFailure describes why a worker failed.
+type WorkerFailure struct {
+	Time time.Time
+	Err  error
+}
+
+// Health reports how many of the Pool's workers are live and busy, and which have failed.
+func (p *Pool) Health() PoolHealth {
+	var health PoolHealth
+	p.workersMu.Lock()
+	for w := range p.workers {
+		health.Live++
+		if w.busy.Load() {
+			health.Busy++
+		}
+	}
+	p.workersMu.Unlock()
+
+	p.failuresMu.Lock()
+	defer p.failuresMu.Unlock()
+	health.Failed = p.failed
+	health.Failures = append([]WorkerFailure(nil), p.failures...)
+	return health
+}
+
+// recordFailure keeps track of a worker's failure for Health.
+func (p *Pool) recordFailure(err error) {
+	p.failuresMu.Lock()
+	defer p.failuresMu.Unlock()
+	p.failed++
+	p.failures = append(p.failures, WorkerFailure{Time: time.Now(), Err: err})
+	if len(p.failures) > maxFailures {
+		p.failures = p.failures[len(p.failures)-maxFailures:]
+	}
+}
+
+// replaceWorker starts a worker in place of one that crashed or was interrupted.
+// Failed attempts are retried after PoolConfig.RestartDelay, doubling every time, until one succeeds or the Pool closes.
+func (p *Pool) replaceWorker() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		delay := p.cfg.RestartDelay
+		for {
+			ready := make(chan error, 1)
+			p.startWorker(ready)
+			err := <-ready
+			if err == nil || p.ctx.Err() != nil {
+				return
+			}
+			p.recordFailure(err)
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-p.ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			delay = min(2*delay, maxRestartDelay)
+		}
+	}()
+}
+
+// replaceAfter replaces a worker that's gone for good. If its replacement was already starting up as pending,
+// that replacement is waited on instead, and only replaced in turn if it fails.
+func (p *Pool) replaceAfter(pending <-chan error) {
+	if pending == nil {
+		p.replaceWorker()
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		select {
+		case <-p.ctx.Done():
+		case err := <-pending:
+			if err != nil && p.ctx.Err() == nil {
+				p.recordFailure(err)
+				p.replaceWorker()
+			}
+		}
+	}()
+}
+
+// isTrap reports whether err came from the WASM trapping or a host function panicking, which wazero recovers into an error.
+// Either leaves the WASM in an unrecoverable state. wazero doesn't export a type for these errors, so this goes by their message.
+func isTrap(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "wasm stack trace:")
+}
@@ -0,0 +1,84 @@
+package gogosseract
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/danlock/pkg/errors"
+)
+
+func TestIsTrap(t *testing.T) {
+	if !isTrap(errors.New("wasm error: unreachable\nwasm stack trace:\n\t.abort()")) {
+		t.Fatalf("isTrap missed a trap")
+	}
+	if isTrap(errors.New("ocrEngine.LoadImage ocrErr=(failed to load image)")) || isTrap(nil) {
+		t.Fatalf("isTrap mistook an error for a trap")
+	}
+}
+
+func TestPool_replaceWorker(t *testing.T) {
+	ctx, cancel := context.WithTimeoutCause(context.Background(), 10*time.Second, errors.New("test timed out"))
+	defer cancel()
+
+	// Invalid training data fails before any WASM is compiled, so every replacement fails fast.
+	p := &Pool{
+		cfg:     PoolConfig{RestartDelay: time.Microsecond},
+		workers: make(map[*worker]struct{}),
+		model:   &model{bytes: []byte("not training data"), cfg: Config{Language: "eng"}},
+	}
+	p.ctx, p.shutdown = context.WithCancelCause(ctx)
+	p.replaceWorker()
+
+	for p.Health().Failed <= maxFailures {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("replaceWorker stopped retrying after %d failures", p.Health().Failed)
+		case <-time.After(time.Millisecond):
+		}
+	}
+	p.shutdown(nil)
+	p.wg.Wait()
+
+	health := p.Health()
+	if health.Live != 0 || len(health.Failures) != maxFailures {
+		t.Fatalf("Pool.Health returned unexpected %+v", health)
+	}
+	for i := 1; i < len(health.Failures); i++ {
+		if health.Failures[i].Time.Before(health.Failures[i-1].Time) {
+			t.Fatalf("Pool.Health failures aren't oldest first")
+		}
+	}
+}
+
+func TestPool_replaceAfter(t *testing.T) {
+	ctx, cancel := context.WithTimeoutCause(context.Background(), 10*time.Second, errors.New("test timed out"))
+	defer cancel()
+
+	p := &Pool{
+		cfg:     PoolConfig{RestartDelay: time.Microsecond},
+		workers: make(map[*worker]struct{}),
+		model:   &model{bytes: []byte("not training data"), cfg: Config{Language: "eng"}},
+	}
+	p.ctx, p.shutdown = context.WithCancelCause(ctx)
+
+	// A worker died while its replacement was starting up, and then the replacement failed too.
+	pending := make(chan error, 1)
+	p.replaceAfter(pending)
+	errReplacement := errors.New("replacement failed")
+	pending <- errReplacement
+
+	// Beyond the replacement's failure, replaceWorker keeps trying to start another worker in its place.
+	for p.Health().Failed < 3 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("replaceAfter didn't replace the failed replacement, Pool.Health returned %+v", p.Health())
+		case <-time.After(time.Millisecond):
+		}
+	}
+	p.shutdown(nil)
+	p.wg.Wait()
+	if failures := p.Health().Failures; !errors.Is(failures[0].Err, errReplacement) {
+		t.Fatalf("Pool.Health didn't record the replacement's failure first, got %+v", failures)
+	}
+}
@@ -0,0 +1,41 @@
+package gogosseract
+
+import (
+	"context"
+	"io"
+)
+
+// ParseImageFunc parses an image like Pool.ParseImage.
+type ParseImageFunc func(ctx context.Context, img io.Reader, opts ParseImageOptions) (string, error)
+
+// Interceptor wraps every image a Pool parses, for concerns like authorization, audit logging and request IDs.
+// It wraps every Pool.Do as well, whose img is nil since fn loads its own images.
+// next passes the request on to the next Interceptor, and eventually the workers. An Interceptor can change the request
+// before calling next, like adding a request ID to ctx, and inspect or change the response afterwards.
+// Returning without calling next short-circuits the request, which never reaches the workers.
+// Set them with PoolConfig.Interceptors. They're called from many goroutines at once.
+type Interceptor func(ctx context.Context, img io.Reader, opts ParseImageOptions, next ParseImageFunc) (string, error)
+
+// intercept parses an image with parse, through every one of PoolConfig.Interceptors.
+func (p *Pool) intercept(ctx context.Context, img io.Reader, opts ParseImageOptions, parse ParseImageFunc) (string, error) {
+	return chainInterceptors(p.cfg.Interceptors, parse)(ctx, img, opts)
+}
+
+// chainInterceptors wraps parse with interceptors, the first of which is called first.
+func chainInterceptors(interceptors []Interceptor, parse ParseImageFunc) ParseImageFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], parse
+		parse = func(ctx context.Context, img io.Reader, opts ParseImageOptions) (string, error) {
+			return interceptor(ctx, img, opts, next)
+		}
+	}
+	return parse
+}
+
+// parseImage sends an image through PoolConfig.Interceptors to the workers.
+func (p *Pool) parseImage(ctx context.Context, img io.Reader, opts ParseImageOptions) (string, error) {
+	return p.intercept(ctx, img, opts, func(ctx context.Context, img io.Reader, opts ParseImageOptions) (string, error) {
+		resp := p.send(workerReq{ctx: ctx, img: img, opts: opts})
+		return resp.str, resp.err
+	})
+}
@@ -0,0 +1,58 @@
+package gogosseract
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/danlock/pkg/errors"
+	"github.com/google/go-cmp/cmp"
+)
+
+type requestIDKey struct{}
+
+func TestPool_intercept(t *testing.T) {
+	var calls []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, img io.Reader, opts ParseImageOptions, next ParseImageFunc) (string, error) {
+			calls = append(calls, name)
+			text, err := next(ctx, img, opts)
+			calls = append(calls, name+" done")
+			return text, err
+		}
+	}
+	addRequestID := func(ctx context.Context, img io.Reader, opts ParseImageOptions, next ParseImageFunc) (string, error) {
+		opts.Priority = 7
+		return next(context.WithValue(ctx, requestIDKey{}, "id"), img, opts)
+	}
+	p := &Pool{cfg: PoolConfig{Interceptors: []Interceptor{record("first"), addRequestID, record("second")}}}
+
+	text, err := p.intercept(context.Background(), strings.NewReader("image"), ParseImageOptions{},
+		func(ctx context.Context, img io.Reader, opts ParseImageOptions) (string, error) {
+			calls = append(calls, "parse")
+			if ctx.Value(requestIDKey{}) != "id" || opts.Priority != 7 {
+				t.Errorf("Interceptor changes didn't reach the parse")
+			}
+			read, err := io.ReadAll(img)
+			return string(read), err
+		})
+	if err != nil || text != "image" {
+		t.Fatalf("Pool.intercept returned %q due to %v", text, err)
+	}
+	if diff := cmp.Diff(calls, []string{"first", "second", "parse", "second done", "first done"}); diff != "" {
+		t.Fatalf(diff)
+	}
+
+	// A short-circuited request never reaches the workers, of which this Pool has none.
+	denied := errors.New("unauthorized")
+	p.cfg.Interceptors = []Interceptor{func(context.Context, io.Reader, ParseImageOptions, ParseImageFunc) (string, error) {
+		return "", denied
+	}}
+	if _, err := p.ParseImage(context.Background(), strings.NewReader("image"), ParseImageOptions{}); !errors.Is(err, denied) {
+		t.Fatalf("Pool.ParseImage returned %v instead of the Interceptor's error", err)
+	}
+	if err := p.Do(context.Background(), func(context.Context, *Tesseract) error { return nil }); !errors.Is(err, denied) {
+		t.Fatalf("Pool.Do returned %v instead of the Interceptor's error", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	_ "embed"
 	"io"
+	"io/fs"
 
 	"github.com/danlock/gogosseract/internal/gen"
 	"github.com/danlock/pkg/errors"
@@ -17,7 +18,9 @@ type CompileConfig struct {
 	Stderr, Stdout io.Writer
 }
 
-func CompileTesseract(ctx context.Context, waRT wazero.Runtime, embEng embind.Engine, cfg CompileConfig) (api.Module, error) {
+// CompileTesseract instantiates Tesseract's WASM. If tessdata isn't nil it's mounted as Tesseract's TESSDATA_PREFIX,
+// where Tesseract looks for the training data of each language after the first in a combined language like "eng+fra".
+func CompileTesseract(ctx context.Context, waRT wazero.Runtime, embEng embind.Engine, cfg CompileConfig, tessdata fs.FS) (api.Module, error) {
 	tessCompiled, err := waRT.CompileModule(ctx, tesseractWASM)
 	if err != nil {
 		return nil, errors.Errorf("waRT.CompileModule %w", err)
@@ -26,10 +29,16 @@ func CompileTesseract(ctx context.Context, waRT wazero.Runtime, embEng embind.En
 	if err := BuildImports(ctx, waRT, embEng, tessCompiled); err != nil {
 		return nil, errors.Wrap(err)
 	}
-	tessMod, err := waRT.InstantiateModule(ctx, tessCompiled, wazero.NewModuleConfig().
+	modCfg := wazero.NewModuleConfig().
 		WithStderr(cfg.Stderr).
 		WithStdout(cfg.Stdout).
-		WithStartFunctions("_initialize"))
+		WithStartFunctions("_initialize")
+	if tessdata != nil {
+		modCfg = modCfg.
+			WithFSConfig(wazero.NewFSConfig().WithFSMount(tessdata, "/tessdata")).
+			WithEnv("TESSDATA_PREFIX", "/tessdata/")
+	}
+	tessMod, err := waRT.InstantiateModule(ctx, tessCompiled, modCfg)
 	if err != nil {
 		return nil, errors.Errorf("waRT.InstantiateModule %w", err)
 	}
@@ -18,7 +18,7 @@ func TestCompileTesseract(t *testing.T) {
 	waRT := wazero.NewRuntime(ctx)
 	defer waRT.Close(ctx)
 
-	_, err := CompileTesseract(ctx, waRT, embEng, CompileConfig{})
+	_, err := CompileTesseract(ctx, waRT, embEng, CompileConfig{}, nil)
 	if err != nil {
 		t.Fatalf("CompileTesseract() error = %v", err)
 	}
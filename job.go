@@ -0,0 +1,105 @@
+package gogosseract
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"slices"
+	"sync/atomic"
+
+	"github.com/danlock/pkg/errors"
+)
+
+// Job is an image submitted to a Pool with Submit, parsing in the background.
+type Job struct {
+	id       uint64
+	cancel   context.CancelCauseFunc
+	done     chan struct{}
+	progress atomic.Int32
+	// text and err are only written before done is closed.
+	text string
+	err  error
+}
+
+// ID identifies the Job within its Pool.
+func (j *Job) ID() uint64 {
+	return j.id
+}
+
+// Done is closed once the Job is finished, successfully or not.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Result waits for the Job to finish, then returns its text or the error it failed with.
+func (j *Job) Result() (string, error) {
+	<-j.done
+	return j.text, j.err
+}
+
+// Progress returns how far along Tesseract is in parsing the image, as a percentage.
+func (j *Job) Progress() int32 {
+	return j.progress.Load()
+}
+
+// Cancel stops the Job, whether it's still waiting for a worker or already being parsed.
+func (j *Job) Cancel() {
+	j.cancel(errors.New("Job canceled"))
+}
+
+// Submit starts parsing an image like ParseImage, but returns right away with a Job to keep track of it.
+// img must not be used until the Job is done. ParseImageOptions.ProgressCB is still called, along with updating Job.Progress.
+func (p *Pool) Submit(ctx context.Context, img io.Reader, opts ParseImageOptions) *Job {
+	ctx, cancel := context.WithCancelCause(ctx)
+	j := &Job{id: p.jobSeq.Add(1), cancel: cancel, done: make(chan struct{})}
+	progressCB := opts.ProgressCB
+	opts.ProgressCB = func(percent int32) {
+		j.progress.Store(percent)
+		if progressCB != nil {
+			progressCB(percent)
+		}
+	}
+
+	done, err := p.track(1)
+	if err != nil {
+		cancel(nil)
+		j.err = errors.Wrap(err)
+		close(j.done)
+		return j
+	}
+	p.jobsMu.Lock()
+	p.jobs[j.id] = j
+	p.jobsMu.Unlock()
+	go func() {
+		defer done()
+		defer cancel(nil)
+		j.text, j.err = p.parseImage(ctx, img, opts)
+		if j.err == nil {
+			j.progress.Store(100)
+		}
+		p.jobsMu.Lock()
+		delete(p.jobs, j.id)
+		p.jobsMu.Unlock()
+		close(j.done)
+	}()
+	return j
+}
+
+// Jobs lists the Pool's unfinished Jobs, oldest first. Finished Jobs are forgotten by the Pool, but not by their handles.
+func (p *Pool) Jobs() []*Job {
+	p.jobsMu.Lock()
+	defer p.jobsMu.Unlock()
+	jobs := make([]*Job, 0, len(p.jobs))
+	for _, j := range p.jobs {
+		jobs = append(jobs, j)
+	}
+	slices.SortFunc(jobs, func(a, b *Job) int { return cmp.Compare(a.id, b.id) })
+	return jobs
+}
+
+// Job returns the unfinished Job with id, or nil if it's finished or never existed.
+func (p *Pool) Job(id uint64) *Job {
+	p.jobsMu.Lock()
+	defer p.jobsMu.Unlock()
+	return p.jobs[id]
+}
@@ -0,0 +1,53 @@
+package gogosseract_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/danlock/gogosseract"
+	"github.com/danlock/pkg/test"
+)
+
+func TestPool_Submit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := gogosseract.NewPool(ctx, 1, gogosseract.PoolConfig{TrainingDataBytes: engTrainedData})
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	var progressed bool
+	docs := pool.Submit(ctx, bytes.NewBuffer(docsImg), gogosseract.ParseImageOptions{ProgressCB: func(int32) { progressed = true }})
+	logo := pool.Submit(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{})
+	canceled := pool.Submit(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{})
+
+	// With a single worker, the last job can't be done yet.
+	if jobs := pool.Jobs(); len(jobs) == 0 || jobs[len(jobs)-1] != canceled || pool.Job(canceled.ID()) != canceled {
+		t.Fatalf("Pool.Jobs didn't list the unfinished jobs, got %v", jobs)
+	}
+	canceled.Cancel()
+	if _, err := canceled.Result(); err == nil {
+		t.Fatalf("canceled Job should have failed")
+	}
+
+	text, err := docs.Result()
+	test.FailOnError(t, err)
+	if text != docsText || docs.Progress() != 100 || !progressed {
+		t.Fatalf("Job returned unexpected text %s at %d%% progress", text, docs.Progress())
+	}
+	select {
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for Job.Done")
+	case <-logo.Done():
+	}
+	text, err = logo.Result()
+	test.FailOnError(t, err)
+	if text != logoText {
+		t.Fatalf("Job returned unexpected text %s", text)
+	}
+	if len(pool.Jobs()) != 0 || pool.Job(docs.ID()) != nil {
+		t.Fatalf("Pool.Jobs listed finished jobs")
+	}
+}
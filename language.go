@@ -0,0 +1,150 @@
+package gogosseract
+
+import (
+	"cmp"
+	"context"
+	"io/fs"
+	"slices"
+	"strings"
+
+	"github.com/danlock/pkg/errors"
+)
+
+// loadLanguage makes lang's model available to the workers, resolving its training data the first time lang is requested.
+func (p *Pool) loadLanguage(ctx context.Context, lang string) error {
+	if lang == p.cfg.Language {
+		return nil
+	}
+	p.languagesMu.Lock()
+	defer p.languagesMu.Unlock()
+	if _, ok := p.languageModels[lang]; ok {
+		return nil
+	}
+	if err := p.ctx.Err(); err != nil {
+		return errors.Errorf("the Pool is closed")
+	}
+	if !p.hasLanguage(lang) {
+		return errors.Errorf("unknown language %s, the Pool supports %s", lang, strings.Join(p.languages(), ", "))
+	}
+	cfg := PoolConfig{Config: p.cfg.Config, TrainingDataBytes: p.cfg.Languages[lang]}
+	cfg.Language = lang
+	// Without TrainingDataBytes, newModel loads the language from Config.Tessdata.
+	m, err := newModel(ctx, &cfg)
+	if err != nil {
+		return errors.Errorf("loading %s training data failed due to %w", lang, err)
+	}
+	p.modelMu.Lock()
+	p.languageModels[lang] = m
+	p.modelMu.Unlock()
+	return nil
+}
+
+// closeLanguages closes the training data of every language besides Config.Language, once the workers are gone.
+func (p *Pool) closeLanguages() []error {
+	p.languagesMu.Lock()
+	defer p.languagesMu.Unlock()
+	p.modelMu.Lock()
+	models := p.languageModels
+	p.languageModels = make(map[string]*model)
+	p.modelMu.Unlock()
+
+	var errs []error
+	for lang, m := range models {
+		if err := m.close(); err != nil {
+			errs = append(errs, errors.Errorf("closing %s training data %w", lang, err))
+		}
+	}
+	return errs
+}
+
+// enforceLanguageBudget evicts the least recently used idle workers of languages besides Config.Language,
+// until the workers fit within PoolConfig.LanguageMemoryBudget.
+func (p *Pool) enforceLanguageBudget() {
+	budget := p.cfg.LanguageMemoryBudget
+	if budget == 0 {
+		return
+	}
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	var total uint64
+	var evictable []*worker
+	for w := range p.workers {
+		if w.retiring || w.evicting {
+			continue
+		}
+		total += w.memory.Load()
+		if !w.busy.Load() && w.language() != p.cfg.Language {
+			evictable = append(evictable, w)
+		}
+	}
+	slices.SortFunc(evictable, func(a, b *worker) int { return cmp.Compare(a.lastUsed.Load(), b.lastUsed.Load()) })
+	for _, w := range evictable {
+		if total <= budget {
+			return
+		}
+		total -= w.memory.Load()
+		p.evictWorkerLocked(w)
+	}
+}
+
+// evictWorkerLocked replaces w with a worker of Config.Language, retiring w once its replacement is serving requests.
+// p.workersMu must be held.
+func (p *Pool) evictWorkerLocked(w *worker) {
+	if w.retiring || w.evicting {
+		return
+	}
+	w.evicting = true
+	ready := make(chan error, 1)
+	p.startWorker(ready)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		if err := <-ready; err != nil {
+			// Keep w around, the next budget check or idle timeout will try again.
+			p.workersMu.Lock()
+			w.evicting = false
+			p.workersMu.Unlock()
+			return
+		}
+		p.retireWorker(w)
+	}()
+}
+
+// evictWorker is evictWorkerLocked for a worker that's gone PoolConfig.LanguageIdleTimeout without a request.
+func (p *Pool) evictWorker(w *worker) {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	p.evictWorkerLocked(w)
+}
+
+// hasLanguage reports whether lang is within PoolConfig.Languages or Config.Tessdata.
+// Every language after the first in a combined language like "eng+fra" must be within Config.Tessdata.
+func (p *Pool) hasLanguage(lang string) bool {
+	first, _, _ := strings.Cut(lang, "+")
+	if _, ok := p.cfg.Languages[lang]; ok {
+		first = ""
+	}
+	if p.cfg.Tessdata == nil {
+		return first == "" && !strings.Contains(lang, "+")
+	}
+	if first != "" {
+		if _, err := fs.Stat(p.cfg.Tessdata, trainingDataFile(first)); err != nil {
+			return false
+		}
+	}
+	return checkCombinedLanguages(p.cfg.Tessdata, lang) == nil
+}
+
+// languages lists every language the Pool supports, starting with Config.Language.
+func (p *Pool) languages() []string {
+	var others []string
+	for lang := range p.cfg.Languages {
+		others = append(others, lang)
+	}
+	if p.cfg.Tessdata != nil {
+		others = append(others, tessdataLanguages(p.cfg.Tessdata)...)
+	}
+	slices.Sort(others)
+	others = slices.Compact(others)
+	return append([]string{p.cfg.Language}, slices.DeleteFunc(others, func(lang string) bool { return lang == p.cfg.Language })...)
+}
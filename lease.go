@@ -0,0 +1,55 @@
+package gogosseract
+
+import (
+	"context"
+	"io"
+
+	"github.com/danlock/pkg/errors"
+)
+
+// Do leases an available worker's Tesseract to fn, for anything ParseImage can't do in one go,
+// like loading an image once and getting both its text and its line boxes.
+// The worker is exclusive to fn until it returns, and tess must not be used afterwards.
+// Then the worker's image is cleared and any variables fn set are restored, ready for the next request.
+// Like with ParseImage, ctx being done interrupts the worker, closing tess.
+// Do fails with PoolConfig.WorkerProcesses, since each Tesseract is within another process.
+// PoolConfig.Interceptors wrap Do too, with a nil image.
+func (p *Pool) Do(ctx context.Context, fn func(ctx context.Context, tess *Tesseract) error) error {
+	if fn == nil {
+		return errors.New("got nil fn")
+	}
+	done, err := p.track(1)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer done()
+	_, err = p.intercept(ctx, nil, ParseImageOptions{}, func(ctx context.Context, _ io.Reader, opts ParseImageOptions) (string, error) {
+		// Options the Interceptors set, like a TenantID or Priority, still decide which worker fn gets and when.
+		return "", p.send(workerReq{ctx: ctx, opts: opts, do: fn}).err
+	})
+	return err
+}
+
+// lease runs fn with t, then clears its image and restores any variables fn set.
+// If t can't be reset, it's retired so the next request doesn't inherit fn's changes.
+func (t *Tesseract) lease(ctx context.Context, fn func(context.Context, *Tesseract) error) error {
+	t.leased = make(map[string]string)
+	err := fn(ctx, t)
+	previous := t.leased
+	t.leased = nil
+	if t.isClosed() {
+		return err
+	}
+
+	// Reset even if ctx is done, otherwise fn's changes would leak into the next request.
+	ctx = context.WithoutCancel(ctx)
+	var resetErr error
+	for name, value := range previous {
+		resetErr = errors.Join(resetErr, t.SetVariable(ctx, name, value))
+	}
+	resetErr = errors.Join(resetErr, t.ClearImage(ctx))
+	if resetErr != nil {
+		return errors.Join(err, errors.Errorf("resetting the worker %w", t.retire(resetErr)))
+	}
+	return err
+}
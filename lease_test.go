@@ -0,0 +1,57 @@
+package gogosseract_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/danlock/gogosseract"
+	"github.com/danlock/pkg/errors"
+	"github.com/danlock/pkg/test"
+)
+
+func TestPool_Do(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// A single worker means every lease gets the same Tesseract.
+	pool, err := gogosseract.NewPool(ctx, 1, gogosseract.PoolConfig{TrainingDataBytes: engTrainedData})
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	test.FailOnError(t, pool.Do(ctx, func(ctx context.Context, tess *gogosseract.Tesseract) error {
+		if err := tess.SetVariable(ctx, "tessedit_pageseg_mode", "7"); err != nil {
+			return err
+		}
+		if err := tess.LoadImage(ctx, bytes.NewBuffer(logoImg), gogosseract.LoadImageOptions{}); err != nil {
+			return err
+		}
+		if _, err := tess.GetText(ctx, nil); err != nil {
+			return err
+		}
+		_, err := tess.GetLineBoxes(ctx)
+		return err
+	}))
+
+	sentinel := errors.New("sentinel")
+	err = pool.Do(ctx, func(ctx context.Context, tess *gogosseract.Tesseract) error {
+		mode, err := tess.GetVariable(ctx, "tessedit_pageseg_mode")
+		if err != nil {
+			return err
+		}
+		if mode != "3" {
+			t.Errorf("Pool.Do didn't restore tessedit_pageseg_mode, got %s", mode)
+		}
+		return sentinel
+	})
+	if !errors.Is(err, sentinel) {
+		t.Fatalf("Pool.Do didn't return fn's error, got %v", err)
+	}
+
+	text, err := pool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{})
+	test.FailOnError(t, err)
+	if text != logoText {
+		t.Fatalf("Pool.ParseImage after Pool.Do returned unexpected text %s", text)
+	}
+}
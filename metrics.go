@@ -0,0 +1,269 @@
+package gogosseract
+
+import (
+	"expvar"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics receives a Pool's measurements as they happen, for exporting to whichever metrics system you use.
+// Set it with PoolConfig.Metrics. Methods are called from many goroutines at once, and should return quickly.
+type Metrics interface {
+	// QueueWait is how long a request waited for an available worker.
+	QueueWait(time.Duration)
+	// ModelLoad is how long a worker took to start up and load the training data.
+	ModelLoad(time.Duration)
+	// ImageLoad is how long a worker took to load a request's image.
+	ImageLoad(time.Duration)
+	// Recognition is how long Tesseract took to recognize a request's text.
+	Recognition(time.Duration)
+	// RequestDone is called once for every request, with the error it failed with or nil.
+	RequestDone(error)
+	// WorkerMemory is a worker's WASM memory, reported after it starts up and after every request.
+	// Once the worker exits, it's reported one last time with a zero Model and Current.
+	WorkerMemory(WorkerMemory)
+	// ResultCache reports whether Config.ResultCache had a request's text, sparing it from the workers.
+	ResultCache(hit bool)
+}
+
+// PoolStats is a snapshot of a Pool's metrics.
+type PoolStats struct {
+	// QueueDepth is how many requests are waiting for an available worker.
+	QueueDepth uint
+	// Workers is the WASM memory of each live worker.
+	Workers []WorkerMemory
+	// BusyWorkers is how many workers are parsing an image right now.
+	BusyWorkers uint
+	// Requests counts every request since NewPool, and Errors counts the ones that failed.
+	Requests uint64
+	Errors   uint64
+	// CacheHits and CacheMisses count the requests Config.ResultCache did and didn't have the text for.
+	CacheHits   uint64
+	CacheMisses uint64
+	// Retries counts every time PoolConfig.RetryPolicy retried a request.
+	Retries uint64
+
+	QueueWait   Histogram
+	ModelLoad   Histogram
+	ImageLoad   Histogram
+	Recognition Histogram
+
+	// Tenants breaks down the requests handed to workers by ParseImageOptions.TenantID.
+	Tenants map[string]TenantStats
+}
+
+// Stats returns a snapshot of the Pool's metrics.
+func (p *Pool) Stats() PoolStats {
+	return PoolStats{
+		QueueDepth:  uint(p.queueDepth.Load()),
+		Workers:     p.MemoryUsage(),
+		BusyWorkers: p.Health().Busy,
+		Requests:    p.metrics.requests.Load(),
+		Errors:      p.metrics.errors.Load(),
+		CacheHits:   p.metrics.cacheHits.Load(),
+		CacheMisses: p.metrics.cacheMisses.Load(),
+		Retries:     p.metrics.retries.Load(),
+		QueueWait:   p.metrics.queueWait.snapshot(),
+		ModelLoad:   p.metrics.modelLoad.snapshot(),
+		ImageLoad:   p.metrics.imageLoad.snapshot(),
+		Recognition: p.metrics.recognition.snapshot(),
+		Tenants:     p.tenants.stats(),
+	}
+}
+
+// histogramBounds spans the quickest image loads up to the slowest model loads and recognitions.
+var histogramBounds = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+}
+
+// Histogram counts durations into buckets.
+type Histogram struct {
+	// Count and Sum are the amount and total of every duration.
+	Count uint64
+	Sum   time.Duration
+	// Bounds are the inclusive upper bounds of each bucket.
+	Bounds []time.Duration
+	// Counts holds the amount of durations within each bucket, plus one last bucket for durations above every bound.
+	Counts []uint64
+}
+
+// Mean returns the average duration, or 0 without any.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// histogram is a Histogram safe for concurrent use.
+type histogram struct {
+	mu sync.Mutex
+	h  Histogram
+}
+
+func (h *histogram) observe(d time.Duration) {
+	bucket, _ := slices.BinarySearch(histogramBounds, d)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.h.Counts == nil {
+		h.h.Bounds = histogramBounds
+		h.h.Counts = make([]uint64, len(histogramBounds)+1)
+	}
+	h.h.Count++
+	h.h.Sum += d
+	h.h.Counts[bucket]++
+}
+
+func (h *histogram) snapshot() Histogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	snapshot := h.h
+	snapshot.Bounds = histogramBounds
+	snapshot.Counts = slices.Clone(h.h.Counts)
+	if snapshot.Counts == nil {
+		snapshot.Counts = make([]uint64, len(histogramBounds)+1)
+	}
+	return snapshot
+}
+
+// poolMetrics records the Pool's metrics for Stats, then passes them along to PoolConfig.Metrics.
+type poolMetrics struct {
+	hook        Metrics
+	requests    atomic.Uint64
+	errors      atomic.Uint64
+	cacheHits   atomic.Uint64
+	cacheMisses atomic.Uint64
+	retries     atomic.Uint64
+	queueWait   histogram
+	modelLoad   histogram
+	imageLoad   histogram
+	recognition histogram
+}
+
+func (m *poolMetrics) QueueWait(d time.Duration) {
+	m.queueWait.observe(d)
+	if m.hook != nil {
+		m.hook.QueueWait(d)
+	}
+}
+
+func (m *poolMetrics) ModelLoad(d time.Duration) {
+	m.modelLoad.observe(d)
+	if m.hook != nil {
+		m.hook.ModelLoad(d)
+	}
+}
+
+func (m *poolMetrics) ImageLoad(d time.Duration) {
+	m.imageLoad.observe(d)
+	if m.hook != nil {
+		m.hook.ImageLoad(d)
+	}
+}
+
+func (m *poolMetrics) Recognition(d time.Duration) {
+	m.recognition.observe(d)
+	if m.hook != nil {
+		m.hook.Recognition(d)
+	}
+}
+
+func (m *poolMetrics) RequestDone(err error) {
+	m.requests.Add(1)
+	if err != nil {
+		m.errors.Add(1)
+	}
+	if m.hook != nil {
+		m.hook.RequestDone(err)
+	}
+}
+
+func (m *poolMetrics) WorkerMemory(memory WorkerMemory) {
+	if m.hook != nil {
+		m.hook.WorkerMemory(memory)
+	}
+}
+
+func (m *poolMetrics) ResultCache(hit bool) {
+	if hit {
+		m.cacheHits.Add(1)
+	} else {
+		m.cacheMisses.Add(1)
+	}
+	if m.hook != nil {
+		m.hook.ResultCache(hit)
+	}
+}
+
+// ExpvarMetrics publishes a Pool's metrics with the expvar package, so they're served at /debug/vars.
+// Durations are published as a count and a total in nanoseconds, like "queue_wait_count" and "queue_wait_ns".
+// "worker_memory_bytes" and "worker_model_memory_bytes" total the memory of every live worker,
+// and "worker_memory_max_bytes" is the memory of the largest one.
+type ExpvarMetrics struct {
+	vars *expvar.Map
+
+	workersMu sync.Mutex
+	workers   map[uint64]WorkerMemory
+}
+
+var _ Metrics = (*ExpvarMetrics)(nil)
+
+// NewExpvarMetrics publishes an expvar.Map under name for PoolConfig.Metrics.
+// Like expvar.Publish, it panics if name is already in use, so share it between Pool's instead of calling this again.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	return newExpvarMetrics(expvar.NewMap(name))
+}
+
+func newExpvarMetrics(vars *expvar.Map) *ExpvarMetrics {
+	return &ExpvarMetrics{vars: vars, workers: make(map[uint64]WorkerMemory)}
+}
+
+func (e *ExpvarMetrics) duration(name string, d time.Duration) {
+	e.vars.Add(name+"_count", 1)
+	e.vars.Add(name+"_ns", int64(d))
+}
+
+func (e *ExpvarMetrics) QueueWait(d time.Duration)   { e.duration("queue_wait", d) }
+func (e *ExpvarMetrics) ModelLoad(d time.Duration)   { e.duration("model_load", d) }
+func (e *ExpvarMetrics) ImageLoad(d time.Duration)   { e.duration("image_load", d) }
+func (e *ExpvarMetrics) Recognition(d time.Duration) { e.duration("recognition", d) }
+
+func (e *ExpvarMetrics) RequestDone(err error) {
+	e.vars.Add("requests", 1)
+	if err != nil {
+		e.vars.Add("errors", 1)
+	}
+}
+
+func (e *ExpvarMetrics) ResultCache(hit bool) {
+	if hit {
+		e.vars.Add("cache_hits", 1)
+	} else {
+		e.vars.Add("cache_misses", 1)
+	}
+}
+
+func (e *ExpvarMetrics) WorkerMemory(memory WorkerMemory) {
+	e.workersMu.Lock()
+	defer e.workersMu.Unlock()
+	if memory.Model == 0 && memory.Current == 0 {
+		delete(e.workers, memory.ID)
+	} else {
+		e.workers[memory.ID] = memory
+	}
+	current, maxCurrent, model := new(expvar.Int), new(expvar.Int), new(expvar.Int)
+	for _, w := range e.workers {
+		current.Add(int64(w.Current))
+		model.Add(int64(w.Model))
+		if int64(w.Current) > maxCurrent.Value() {
+			maxCurrent.Set(int64(w.Current))
+		}
+	}
+	e.vars.Set("worker_memory_bytes", current)
+	e.vars.Set("worker_memory_max_bytes", maxCurrent)
+	e.vars.Set("worker_model_memory_bytes", model)
+}
@@ -0,0 +1,75 @@
+package gogosseract
+
+import (
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/danlock/pkg/errors"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHistogram(t *testing.T) {
+	var h histogram
+	if empty := h.snapshot(); empty.Mean() != 0 || len(empty.Counts) != len(histogramBounds)+1 {
+		t.Fatalf("empty histogram has unexpected snapshot %+v", empty)
+	}
+	for _, d := range []time.Duration{0, time.Millisecond, 2 * time.Millisecond, time.Second, time.Hour} {
+		h.observe(d)
+	}
+	snapshot := h.snapshot()
+	if snapshot.Count != 5 || snapshot.Sum != time.Hour+time.Second+3*time.Millisecond {
+		t.Fatalf("histogram has unexpected Count %d and Sum %v", snapshot.Count, snapshot.Sum)
+	}
+	want := make([]uint64, len(histogramBounds)+1)
+	want[0] = 2 // bounds are inclusive, so 0 and 1ms
+	want[1] = 1
+	want[8] = 1
+	want[len(histogramBounds)] = 1
+	if diff := cmp.Diff(want, snapshot.Counts); diff != "" {
+		t.Fatalf("histogram has unexpected Counts (-want +got):\n%s", diff)
+	}
+	if snapshot.Mean() != snapshot.Sum/5 {
+		t.Fatalf("Histogram.Mean returned %v", snapshot.Mean())
+	}
+}
+
+func TestExpvarMetrics(t *testing.T) {
+	var m poolMetrics
+	// An unpublished expvar.Map, since publishing the same name twice panics under go test -count=2.
+	vars := new(expvar.Map)
+	m.hook = newExpvarMetrics(vars)
+	m.QueueWait(time.Second)
+	m.QueueWait(time.Second)
+	m.Recognition(time.Millisecond)
+	m.RequestDone(nil)
+	m.RequestDone(errors.New("failed"))
+	m.WorkerMemory(WorkerMemory{ID: 1, Model: 10, Current: 20})
+	m.WorkerMemory(WorkerMemory{ID: 2, Model: 10, Current: 30})
+	m.WorkerMemory(WorkerMemory{ID: 3, Model: 10, Current: 40})
+	m.WorkerMemory(WorkerMemory{ID: 3})
+	m.ResultCache(true)
+	m.ResultCache(false)
+	m.ResultCache(false)
+
+	if m.requests.Load() != 2 || m.errors.Load() != 1 || m.queueWait.snapshot().Count != 2 {
+		t.Fatalf("poolMetrics didn't record its own metrics")
+	}
+	for key, want := range map[string]string{
+		"queue_wait_count":          "2",
+		"queue_wait_ns":             "2000000000",
+		"recognition_count":         "1",
+		"recognition_ns":            "1000000",
+		"requests":                  "2",
+		"errors":                    "1",
+		"worker_memory_bytes":       "50",
+		"worker_memory_max_bytes":   "30",
+		"worker_model_memory_bytes": "20",
+		"cache_hits":                "1",
+		"cache_misses":              "2",
+	} {
+		if got := vars.Get(key); got == nil || got.String() != want {
+			t.Fatalf("ExpvarMetrics %s was %v instead of %s", key, got, want)
+		}
+	}
+}
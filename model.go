@@ -0,0 +1,207 @@
+package gogosseract
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	"github.com/danlock/pkg/errors"
+)
+
+// model is the training data and Config that a Pool's workers start up with.
+type model struct {
+	// id identifies the training data and Config.Variables for ResultCache keys, if the Pool has one.
+	id       string
+	cfg      Config
+	bytes    []byte
+	readerAt io.ReaderAt
+	size     int64
+	// file is the Config.Tessdata file readerAt streams from, if the Pool opened it.
+	file io.Closer
+	// loading counts the workers reading the training data, so file isn't closed out from under them.
+	loading sync.WaitGroup
+}
+
+// newModel resolves the training data within cfg, preferring sources that workers can stream from.
+// cfg.Tessdata is set to TESSDATA_PREFIX if the training data comes from there.
+func newModel(ctx context.Context, cfg *PoolConfig) (_ *model, err error) {
+	m := &model{bytes: cfg.TrainingDataBytes, readerAt: cfg.TrainingDataReaderAt}
+	if m.readerAt == nil && m.bytes == nil && cfg.TrainingData == nil {
+		if cfg.Tessdata, err = tessdataOrEnv(cfg.Tessdata); err != nil {
+			return nil, errors.Errorf("requires either PoolConfig.TrainingDataBytes, PoolConfig.TrainingDataReaderAt, Config.TrainingData or Config.Tessdata, and %w", err)
+		}
+		trainingData, err := openTrainingData(cfg.Tessdata, cfg.Language)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		if readerAt, ok := trainingData.(io.ReaderAt); ok {
+			// Keep the file open for the workers to stream from, the model closes it.
+			m.readerAt, m.file = readerAt, trainingData
+		} else {
+			defer trainingData.Close()
+			cfg.TrainingData = trainingData
+		}
+	}
+
+	if m.readerAt != nil {
+		m.bytes = nil
+		if m.size, err = readerAtSize(m.readerAt); err != nil {
+			return nil, errors.Join(errors.Wrap(err), m.close())
+		}
+	} else if m.bytes == nil {
+		if m.bytes, err = io.ReadAll(cfg.TrainingData); err != nil {
+			return nil, errors.Errorf("reading cfg.TrainingData failed because %w", err)
+		}
+	}
+	m.cfg = cfg.Config
+	m.cfg.TrainingData = nil
+	if m.cfg.ResultCache != nil {
+		trainingData := m.trainingData()
+		trainingDataID, err := trainingDataID(ctx, &trainingData)
+		if err != nil {
+			return nil, errors.Join(errors.Wrap(err), m.close())
+		}
+		m.id = trainingDataID + " " + optionsKey("", false, LoadImageOptions{}, false, m.cfg.Variables)
+		// The Pool checks the cache before handing requests to workers, so they don't need to.
+		m.cfg.ResultCache = nil
+	}
+	return m, nil
+}
+
+// trainingData returns a fresh reader of the model's training data for a worker to load.
+func (m *model) trainingData() io.Reader {
+	if m.readerAt != nil {
+		return io.NewSectionReader(m.readerAt, 0, m.size)
+	}
+	return bytes.NewReader(m.bytes)
+}
+
+// close waits for workers to finish loading the training data, then closes the file it streams from.
+// The model must no longer be the Pool's, so no more workers start loading it.
+func (m *model) close() error {
+	if m == nil {
+		// The Pool was already closed.
+		return nil
+	}
+	m.loading.Wait()
+	if m.file == nil {
+		return nil
+	}
+	return m.file.Close()
+}
+
+// acquireModel returns the model a worker should load for lang, which loadLanguage must have loaded already.
+// loaded must be called once the worker is done loading it.
+func (p *Pool) acquireModel(lang string) (_ *model, loaded func()) {
+	p.modelMu.Lock()
+	defer p.modelMu.Unlock()
+	m := p.model
+	if lang != p.cfg.Language {
+		m = p.languageModels[lang]
+	}
+	m.loading.Add(1)
+	return m, m.loading.Done
+}
+
+// modelID returns the id of the model workers load for lang.
+func (p *Pool) modelID(lang string) string {
+	p.modelMu.Lock()
+	defer p.modelMu.Unlock()
+	if lang != p.cfg.Language {
+		return p.languageModels[lang].id
+	}
+	return p.model.id
+}
+
+// swapModel makes m the model new workers start up with, returning the previous one.
+func (p *Pool) swapModel(m *model) *model {
+	p.modelMu.Lock()
+	defer p.modelMu.Unlock()
+	prev := p.model
+	p.model = m
+	return prev
+}
+
+// ReloadModel rolls the Pool's workers over to new training data, like a newer traineddata version, without downtime.
+// The training data comes from cfg.TrainingData or cfg.Tessdata just like with NewPool, and the rest of cfg
+// replaces the Pool's Config for the new workers. Config.Language and Config.ResultCache can't change.
+//
+// Workers are replaced one at a time. Each replacement starts up before the worker it replaces retires,
+// so the Pool briefly runs an extra worker, and retiring workers finish their current request first.
+// If any replacement fails to start, the Pool rolls back to the previous training data and the error is returned.
+// Only Config.Language's training data is replaced, so workers with one of PoolConfig.Languages loaded are left alone.
+func (p *Pool) ReloadModel(ctx context.Context, cfg Config) error {
+	if cfg.Language == "" {
+		cfg.Language = p.cfg.Language
+	} else if cfg.Language != p.cfg.Language {
+		return errors.Errorf("can't change the Pool's language from %s to %s", p.cfg.Language, cfg.Language)
+	}
+	if cfg.WASMCache == nil {
+		cfg.WASMCache = p.cfg.WASMCache
+	}
+	// The Pool checks its ResultCache before any worker sees a request, so that can't change either.
+	cfg.ResultCache = p.cfg.ResultCache
+	cfg.CloseOnContextDone = true
+	next, err := newModel(ctx, &PoolConfig{Config: cfg})
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+	if err := p.ctx.Err(); err != nil {
+		return errors.Join(errors.Errorf("the Pool is closed"), next.close())
+	}
+	prev := p.swapModel(next)
+	if err := p.rollout(ctx, next); err != nil {
+		p.swapModel(prev)
+		// The previous model was running fine, so only the Pool closing should stop the rollback.
+		rollbackErr := p.rollout(p.ctx, prev)
+		return errors.Errorf("rolled back due to %w", errors.Join(err, rollbackErr, next.close()))
+	}
+	return errors.Wrap(prev.close())
+}
+
+// rollout replaces every worker that isn't running m, one at a time.
+// Each replacement starts up with the Pool's current model before the worker it replaces retires.
+func (p *Pool) rollout(ctx context.Context, m *model) error {
+	for {
+		old := p.outdatedWorker(m)
+		if old == nil {
+			return nil
+		}
+		ready := make(chan error, 1)
+		p.startWorker(ready)
+		// Wait for the replacement even if ctx is done, so it can't outlast a rollback.
+		if err := <-ready; err != nil {
+			return errors.Errorf("starting a worker failed due to %w", err)
+		}
+		p.retireWorker(old)
+		if err := ctx.Err(); err != nil {
+			return errors.Errorf("while rolling out %w", context.Cause(ctx))
+		}
+	}
+}
+
+// outdatedWorker returns a worker of m's language that isn't running m and isn't already retiring, or nil if there are none.
+func (p *Pool) outdatedWorker(m *model) *worker {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	for w := range p.workers {
+		if w.model != m && w.language() == m.cfg.Language && !w.retiring {
+			return w
+		}
+	}
+	return nil
+}
+
+// retireWorker makes w exit once it's done with its current request.
+func (p *Pool) retireWorker(w *worker) {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	if !w.retiring {
+		w.retiring = true
+		close(w.retire)
+	}
+}
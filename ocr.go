@@ -3,7 +3,11 @@ package gogosseract
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"image"
 	"io"
+	"io/fs"
+	"strings"
 
 	"github.com/danlock/gogosseract/internal/gen"
 	"github.com/danlock/gogosseract/internal/wasm"
@@ -16,36 +20,95 @@ import (
 type Config struct {
 	wasm.CompileConfig
 	// Languages Tesseract scans for. Defaults to "eng".
+	// Combined languages like "eng+fra" take the first language's training data from TrainingData,
+	// and the others from Tessdata or TESSDATA_PREFIX.
 	Language string
-	// Training Data Tesseract uses. Required. Must support the provided language. https://github.com/tesseract-ocr/tessdata_fast for more details.
+	// Training Data Tesseract uses. Must support the provided language. https://github.com/tesseract-ocr/tessdata_fast for more details.
+	// Required unless Tessdata or the TESSDATA_PREFIX environment variable are set instead.
 	TrainingData io.Reader
+	// Tessdata is a directory of training data files named like "<lang>.traineddata", such as an embed.FS or os.DirFS.
+	// If TrainingData is nil, Language's training data is loaded from here, or from TESSDATA_PREFIX if this is nil too.
+	Tessdata fs.FS
 	// Variables are optionally passed into Tesseract as variable config options. Some options are listed at http://www.sk-spell.sk.cx/tesseract-ocr-parameters-in-302-version
 	Variables map[string]string
 	// WASMCache is an optional wazero.CompilationCache used for running multiple Tesseract instances more efficiently.
 	WASMCache wazero.CompilationCache
+	// CloseOnContextDone lets a done context interrupt Tesseract in the middle of a call, like a long running GetText.
+	// An interrupted Tesseract is closed, so it's only good for calling Close on. Slightly slows down Tesseract.
+	CloseOnContextDone bool
+	// ResultCache stores the text of every image GetText or GetHOCR recognizes, so the same image
+	// with the same training data, language, variables and options is only recognized once.
+	// Hashing the training data slows down New, and hashing every image slows down LoadImage a little.
+	// With a Pool, cache hits skip the workers entirely.
+	ResultCache ResultCache
+	// memoryLimit caps the WASM memory in bytes, for WorkerProcessConfig.MemoryLimit.
+	memoryLimit uint64
 }
 
+// wasmPageSize is the size of a page of WASM memory in bytes.
+const wasmPageSize = 65536
+
 // New creates a new Tesseract class that is ready for use.
 // The Tesseract WASM is initialized with the given trainingdata, language and variable options.
 // Each Tesseract object is NOT safe for concurrent use.
 func New(ctx context.Context, cfg Config) (t *Tesseract, err error) {
+	if cfg.Language == "" {
+		cfg.Language = "eng"
+	}
+	combined := strings.Contains(cfg.Language, "+")
+	if combined {
+		if cfg.Tessdata, err = tessdataOrEnv(cfg.Tessdata); err != nil {
+			return nil, errors.Errorf("Config.Tessdata is required for the combined Config.Language %s, and %w", cfg.Language, err)
+		}
+		if err := checkCombinedLanguages(cfg.Tessdata, cfg.Language); err != nil {
+			return nil, errors.Wrap(err)
+		}
+	}
 	if cfg.TrainingData == nil {
-		return nil, errors.Errorf("Config.TrainingData is required")
+		tessdata, err := tessdataOrEnv(cfg.Tessdata)
+		if err != nil {
+			return nil, errors.Errorf("Config.TrainingData or Config.Tessdata is required, and %w", err)
+		}
+		file, err := openTrainingData(tessdata, cfg.Language)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		defer file.Close()
+		cfg.TrainingData = file
+	}
+
+	if err := validateTrainingData(ctx, &cfg.TrainingData); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	var modelID string
+	if cfg.ResultCache != nil {
+		if modelID, err = trainingDataID(ctx, &cfg.TrainingData); err != nil {
+			return nil, errors.Wrap(err)
+		}
 	}
 
 	t = &Tesseract{
 		embindEngine: embind.CreateEngine(embind.NewConfig()),
 		cfg:          cfg,
+		modelID:      modelID,
 	}
-	waRTCfg := wazero.NewRuntimeConfig()
+	waRTCfg := wazero.NewRuntimeConfig().WithCloseOnContextDone(cfg.CloseOnContextDone)
 	if t.cfg.WASMCache != nil {
 		waRTCfg = waRTCfg.WithCompilationCache(t.cfg.WASMCache)
 	}
+	if t.cfg.memoryLimit > 0 {
+		// WASM memory can't grow past 4GiB anyway.
+		waRTCfg = waRTCfg.WithMemoryLimitPages(uint32(max(1, min(t.cfg.memoryLimit/wasmPageSize, 65536))))
+	}
 	t.waRT = wazero.NewRuntimeWithConfig(ctx, waRTCfg)
 
 	ctx = t.embindEngine.Attach(ctx)
 
-	t.module, err = wasm.CompileTesseract(ctx, t.waRT, t.embindEngine, cfg.CompileConfig)
+	var tessdata fs.FS
+	if combined {
+		tessdata = cfg.Tessdata
+	}
+	t.module, err = wasm.CompileTesseract(ctx, t.waRT, t.embindEngine, cfg.CompileConfig, tessdata)
 	if err != nil {
 		return nil, errors.Errorf("%w", err)
 	}
@@ -61,14 +124,12 @@ func New(ctx context.Context, cfg Config) (t *Tesseract, err error) {
 	}
 	defer trainingDataView.Delete(ctx)
 
-	if cfg.Language == "" {
-		cfg.Language = "eng"
-	}
-
 	ocrErr, err := t.ocrEngine.LoadModel(ctx, trainingDataView, cfg.Language)
 	if err != nil || ocrErr != "" {
 		return nil, errors.Errorf("ocrEngine.LoadModel ocrErr (%s) %w", ocrErr, err)
 	}
+	// The model lives in WASM memory now, so don't hold on to the training data, which may have been buffered on the Go heap.
+	t.cfg.TrainingData = nil
 
 	if len(cfg.Variables) == 0 {
 		cfg.Variables = map[string]string{
@@ -77,9 +138,8 @@ func New(ctx context.Context, cfg Config) (t *Tesseract, err error) {
 	}
 
 	for k, v := range cfg.Variables {
-		ocrErr, err := t.ocrEngine.SetVariable(ctx, k, v)
-		if err != nil || ocrErr != "" {
-			return nil, errors.Errorf("ocrEngine.SetVariable ocrErr (%s) %w", ocrErr, err)
+		if err := t.SetVariable(ctx, k, v); err != nil {
+			return nil, errors.Wrap(err)
 		}
 	}
 
@@ -92,6 +152,15 @@ type Tesseract struct {
 	module       api.Module
 	ocrEngine    *gen.ClassOCREngine
 	cfg          Config
+	// leased holds the previous value of every variable set during a Pool.Do, so they can be restored afterwards.
+	leased map[string]string
+
+	// modelID, variables and the loaded image's hash and options make up the Config.ResultCache key.
+	modelID     string
+	variables   map[string]string
+	imageHash   [sha256.Size]byte
+	imageHashed bool
+	imageOpts   LoadImageOptions
 }
 
 type LoadImageOptions struct {
@@ -107,6 +176,11 @@ func (t *Tesseract) LoadImage(ctx context.Context, img io.Reader, opts LoadImage
 	if err := t.ClearImage(ctx); err != nil {
 		return errors.Wrap(err)
 	}
+	if t.cfg.ResultCache != nil {
+		// Without a hash the image isn't cached, and it's most likely invalid anyway.
+		hash, _, err := hashReader(ctx, &img)
+		t.imageHash, t.imageHashed, t.imageOpts = hash, err == nil, opts
+	}
 
 	imgByteView, err := t.createByteView(ctx, img)
 	if err != nil {
@@ -125,22 +199,89 @@ func (t *Tesseract) LoadImage(ctx context.Context, img io.Reader, opts LoadImage
 
 // ClearImage clears the image from within Tesseract. LoadImage calls this for you.
 func (t *Tesseract) ClearImage(ctx context.Context) error {
+	t.imageHashed = false
 	if err := t.ocrEngine.ClearImage(ctx); err != nil {
 		return errors.Errorf("ocrEngine.ClearImage %w", err)
 	}
 	return nil
 }
 
+// SetVariable sets one of Tesseract's config variables. Some variables only take effect during New, so set those in Config.Variables instead.
+func (t *Tesseract) SetVariable(ctx context.Context, name, value string) error {
+	if _, ok := t.leased[name]; t.leased != nil && !ok {
+		previous, err := t.GetVariable(ctx, name)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		t.leased[name] = previous
+	}
+	ocrErr, err := t.ocrEngine.SetVariable(ctx, name, value)
+	if err != nil || ocrErr != "" {
+		return errors.Errorf("ocrEngine.SetVariable %s ocrErr (%s) %w", name, ocrErr, err)
+	}
+	if t.variables == nil {
+		t.variables = make(map[string]string)
+	}
+	t.variables[name] = value
+	return nil
+}
+
+// GetVariable gets the current value of one of Tesseract's config variables.
+func (t *Tesseract) GetVariable(ctx context.Context, name string) (string, error) {
+	result, err := t.ocrEngine.GetVariable(ctx, name)
+	if err != nil {
+		return "", errors.Errorf("ocrEngine.GetVariable %s %w", name, err)
+	}
+	if success, _ := result["success"].(bool); !success {
+		return "", errors.Errorf("ocrEngine.GetVariable %s failed, is it a real variable?", name)
+	}
+	value, _ := result["value"].(string)
+	return value, nil
+}
+
+// overrideVariables sets every variable, returning a func that restores their previous values.
+// restore is returned even alongside an error if any variable may have been changed.
+func (t *Tesseract) overrideVariables(ctx context.Context, variables map[string]string) (restore func(context.Context) error, err error) {
+	previous := make(map[string]string, len(variables))
+	for name := range variables {
+		if previous[name], err = t.GetVariable(ctx, name); err != nil {
+			return nil, errors.Wrap(err)
+		}
+	}
+
+	restore = func(ctx context.Context) error {
+		for name, value := range previous {
+			if err := t.SetVariable(ctx, name, value); err != nil {
+				return errors.Wrap(err)
+			}
+		}
+		return nil
+	}
+	for name, value := range variables {
+		if err := t.SetVariable(ctx, name, value); err != nil {
+			return restore, errors.Wrap(err)
+		}
+	}
+	return restore, nil
+}
+
 // GetText parses a previously loaded image for text. progressCB is called with a percentage
 // for tracking Tesseract's recognition progress.
 func (t *Tesseract) GetText(ctx context.Context, progressCB func(int32)) (string, error) {
 	if progressCB == nil {
 		progressCB = func(i int32) {}
 	}
+	key, text, hit := t.cachedResult(false, progressCB)
+	if hit {
+		return text, nil
+	}
 	text, err := t.ocrEngine.GetText(ctx, progressCB)
 	if err != nil {
 		return "", errors.Errorf("ocrEngine.GetText %w", err)
 	}
+	if key != "" {
+		t.cfg.ResultCache.Set(key, text)
+	}
 	return text, nil
 }
 
@@ -150,15 +291,77 @@ func (t *Tesseract) GetHOCR(ctx context.Context, progressCB func(int32)) (string
 	if progressCB == nil {
 		progressCB = func(i int32) {}
 	}
+	key, text, hit := t.cachedResult(true, progressCB)
+	if hit {
+		return text, nil
+	}
 	text, err := t.ocrEngine.GetHOCR(ctx, progressCB)
 	if err != nil {
 		return "", errors.Errorf("ocrEngine.GetHOCR %w", err)
 	}
+	if key != "" {
+		t.cfg.ResultCache.Set(key, text)
+	}
 	return text, nil
 }
 
+// cachedResult returns the loaded image's text from Config.ResultCache if it's there, reporting 100% progress.
+// Otherwise key is what the text should be stored under, or empty if it can't be cached.
+func (t *Tesseract) cachedResult(isHOCR bool, progressCB func(int32)) (key, text string, hit bool) {
+	if t.cfg.ResultCache == nil || !t.imageHashed {
+		return "", "", false
+	}
+	key = resultKey(t.imageHash, t.modelID, optionsKey(t.cfg.Language, isHOCR, t.imageOpts, false, t.variables))
+	if text, hit = t.cfg.ResultCache.Get(key); hit {
+		progressCB(100)
+	}
+	return key, text, hit
+}
+
+// GetLineBoxes runs Tesseract's layout analysis on a previously loaded image without recognizing any text.
+// It returns the bounding box of every text line in reading order.
+func (t *Tesseract) GetLineBoxes(ctx context.Context) ([]image.Rectangle, error) {
+	boxesI, err := t.ocrEngine.GetBoundingBoxes(ctx, gen.EnumTextUnit_Line)
+	if err != nil {
+		return nil, errors.Errorf("ocrEngine.GetBoundingBoxes %w", err)
+	}
+	if boxesI == nil {
+		return nil, errors.New("ocrEngine.GetBoundingBoxes returned nil")
+	}
+	boxes := &gen.ClassVector_IntRect_{ClassBase: boxesI}
+	defer boxes.Delete(ctx)
+
+	size, err := boxes.Size(ctx)
+	if err != nil {
+		return nil, errors.Errorf("boxes.Size %w", err)
+	}
+	rects := make([]image.Rectangle, 0, size)
+	for i := uint32(0); i < size; i++ {
+		boxI, err := boxes.Get(ctx, i)
+		if err != nil {
+			return nil, errors.Errorf("boxes.Get %w", err)
+		}
+		box, ok := boxI.(map[string]any)
+		if !ok {
+			return nil, errors.Errorf("boxI unexpected type %T", boxI)
+		}
+		rects = append(rects, image.Rect(toInt(box["left"]), toInt(box["top"]), toInt(box["right"]), toInt(box["bottom"])))
+	}
+	return rects, nil
+}
+
+// toInt converts the int32 embind gives us for a C++ int into a Go int.
+func toInt(num any) int {
+	n, _ := num.(int32)
+	return int(n)
+}
+
 // Close shuts down all the resources associated with the Tesseract object.
 func (t *Tesseract) Close(ctx context.Context) error {
+	if t.isClosed() {
+		// The WASM module was interrupted due to Config.CloseOnContextDone, so there's nothing left to clean up within it.
+		return t.waRT.Close(ctx)
+	}
 	if err := t.ClearImage(ctx); err != nil {
 		return errors.Wrap(err)
 	}
@@ -169,6 +372,19 @@ func (t *Tesseract) Close(ctx context.Context) error {
 	return t.waRT.Close(ctx)
 }
 
+// isClosed reports whether the Tesseract WASM module was closed, making this Tesseract unusable.
+func (t *Tesseract) isClosed() bool {
+	return t.module.IsClosed()
+}
+
+// exited never fires, since a Tesseract only stops when it's interrupted or closed.
+func (t *Tesseract) exited() <-chan struct{} { return nil }
+
+// memorySize returns the current size of the Tesseract WASM module's linear memory in bytes.
+func (t *Tesseract) memorySize() uint64 {
+	return uint64(t.module.Memory().Size())
+}
+
 // createByteView streams an io.Reader into WASM memory using io.Copy, emscripten::typed_memory_view and minimal memory.
 // Works optimally if io.Reader is an io.ReadSeeker (like an os.File) or a bytes.Buffer.
 func (t *Tesseract) createByteView(ctx context.Context, reader io.Reader) (*gen.ClassByteView, error) {
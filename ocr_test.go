@@ -6,10 +6,13 @@ import (
 	_ "embed"
 	"io"
 	"os"
+	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/danlock/gogosseract"
+	"github.com/danlock/pkg/test"
 	"github.com/google/go-cmp/cmp"
 	"github.com/tetratelabs/wazero"
 	"golang.org/x/sync/errgroup"
@@ -36,6 +39,20 @@ func TestNewTesseract(t *testing.T) {
 			gogosseract.Config{TrainingData: bytes.NewBuffer([]byte{}), WASMCache: cache},
 			true,
 		},
+		{
+			"not training data",
+			gogosseract.Config{TrainingData: JustAReader{bytes.NewBufferString("<html>404 not found</html>")}, WASMCache: cache},
+			true,
+		},
+		{
+			"combined languages without tessdata",
+			gogosseract.Config{
+				TrainingData: bytes.NewBuffer(engTrainedData),
+				Language:     "eng+fra",
+				WASMCache:    cache,
+			},
+			true,
+		},
 		{
 			"bad variables",
 			gogosseract.Config{
@@ -79,6 +96,94 @@ func TestNewTesseract(t *testing.T) {
 	}
 }
 
+func TestNewTesseract_Tessdata(t *testing.T) {
+	ctx := context.Background()
+	cache := wazero.NewCompilationCache()
+	tessdata := fstest.MapFS{"eng.traineddata": &fstest.MapFile{Data: engTrainedData}}
+
+	_, err := gogosseract.New(ctx, gogosseract.Config{Tessdata: tessdata, Language: "fra", WASMCache: cache})
+	if err == nil || !strings.Contains(err.Error(), "available languages are (eng)") {
+		t.Fatalf("gogosseract.New should have failed listing the available languages, got %v", err)
+	}
+
+	tess, err := gogosseract.New(ctx, gogosseract.Config{Tessdata: tessdata, WASMCache: cache})
+	test.FailOnError(t, err)
+	test.FailOnError(t, tess.Close(ctx))
+
+	t.Setenv("TESSDATA_PREFIX", "internal/wasm/testdata")
+	tess, err = gogosseract.New(ctx, gogosseract.Config{WASMCache: cache})
+	test.FailOnError(t, err)
+	test.FailOnError(t, tess.Close(ctx))
+}
+
+func TestTesseract_CombinedLanguages(t *testing.T) {
+	ctx := context.Background()
+	tessdata := fstest.MapFS{
+		"eng.traineddata":     &fstest.MapFile{Data: engTrainedData},
+		"engcopy.traineddata": &fstest.MapFile{Data: engTrainedData},
+	}
+
+	_, err := gogosseract.New(ctx, gogosseract.Config{Tessdata: tessdata, Language: "eng+fra"})
+	if err == nil || !strings.Contains(err.Error(), "combines fra") {
+		t.Fatalf("gogosseract.New should have failed on the missing fra, got %v", err)
+	}
+
+	tess, err := gogosseract.New(ctx, gogosseract.Config{Tessdata: tessdata, Language: "eng+engcopy"})
+	test.FailOnError(t, err)
+	defer tess.Close(ctx)
+	test.FailOnError(t, tess.LoadImage(ctx, bytes.NewBuffer(logoImg), gogosseract.LoadImageOptions{}))
+	text, err := tess.GetText(ctx, nil)
+	test.FailOnError(t, err)
+	if text != logoText {
+		t.Fatalf("GetText() = %q, want %q", text, logoText)
+	}
+}
+
+// countingCache counts the hits of an LRUCache.
+type countingCache struct {
+	*gogosseract.LRUCache
+	hits int
+}
+
+func (c *countingCache) Get(key string) (string, bool) {
+	text, ok := c.LRUCache.Get(key)
+	if ok {
+		c.hits++
+	}
+	return text, ok
+}
+
+func TestTesseract_ResultCache(t *testing.T) {
+	ctx := context.Background()
+	cache := &countingCache{LRUCache: gogosseract.NewLRUCache(10)}
+	tess, err := gogosseract.New(ctx, gogosseract.Config{TrainingData: bytes.NewBuffer(engTrainedData), ResultCache: cache})
+	test.FailOnError(t, err)
+	defer tess.Close(ctx)
+
+	getText := func() string {
+		t.Helper()
+		// A reader that can't be read twice still works, since it gets buffered for hashing.
+		test.FailOnError(t, tess.LoadImage(ctx, JustAReader{bytes.NewBuffer(logoImg)}, gogosseract.LoadImageOptions{}))
+		text, err := tess.GetText(ctx, nil)
+		test.FailOnError(t, err)
+		return text
+	}
+	for i := 0; i < 2; i++ {
+		if text := getText(); text != logoText {
+			t.Fatalf("Tesseract.GetText returned unexpected text %s", text)
+		}
+	}
+	if cache.hits != 1 {
+		t.Fatalf("Tesseract.GetText hit the cache %d times instead of once", cache.hits)
+	}
+	// Changing a variable changes the key.
+	test.FailOnError(t, tess.SetVariable(ctx, "tessedit_pageseg_mode", "7"))
+	getText()
+	if cache.hits != 1 {
+		t.Fatalf("Tesseract.GetText hit the cache after changing a variable")
+	}
+}
+
 type JustAReader struct {
 	buf *bytes.Buffer
 }
@@ -0,0 +1,182 @@
+package gogosseract
+
+import (
+	"bytes"
+	"context"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/danlock/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// regionPadding is the margin of pixels kept around each region, since Tesseract struggles with text touching the image's edge.
+const regionPadding = 4
+
+type subImager interface {
+	SubImage(r image.Rectangle) image.Image
+}
+
+// ParseImageParallel parses a single image like ParseImage, but splits it into regions that are recognized concurrently
+// by idle workers. A worker first runs Tesseract's layout analysis over the whole image, then the lines it found
+// are grouped into regions that are cropped out and parsed separately. The text is stitched back together in reading order.
+// This trades throughput for latency, since the image is loaded once for layout analysis and once more for every region.
+// Only images Go can decode (PNG, JPEG and GIF) can be split, anything else is handed to ParseImage whole.
+// IsHOCR is not supported.
+func (p *Pool) ParseImageParallel(ctx context.Context, img io.Reader, opts ParseImageOptions) (string, error) {
+	if opts.IsHOCR {
+		return "", errors.New("ParseImageParallel doesn't support IsHOCR")
+	}
+	if img == nil {
+		return "", errors.New("got nil io.Reader")
+	}
+	done, err := p.track(1)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	defer done()
+	return p.intercept(ctx, img, opts, p.parseImageParallel)
+}
+
+// parseImageParallel is ParseImageParallel after PoolConfig.Interceptors.
+func (p *Pool) parseImageParallel(ctx context.Context, img io.Reader, opts ParseImageOptions) (string, error) {
+	imgBytes, err := io.ReadAll(img)
+	if err != nil {
+		return "", errors.Errorf("io.ReadAll %w", err)
+	}
+
+	capacity := p.capacity()
+	decoded, _, err := image.Decode(bytes.NewReader(imgBytes))
+	page, ok := decoded.(subImager)
+	if err != nil || !ok || capacity < 2 {
+		// Leptonica understands more image formats than Go, so let a single worker handle it.
+		resp := p.send(workerReq{ctx: ctx, img: bytes.NewReader(imgBytes), opts: opts})
+		return resp.str, resp.err
+	}
+
+	layout := p.send(workerReq{ctx: ctx, img: bytes.NewReader(imgBytes), opts: opts, layout: true})
+	if layout.err != nil {
+		return "", errors.Errorf("layout analysis failed due to %w", layout.err)
+	}
+	regions := groupLines(layout.rects, int(capacity), decoded.Bounds())
+	if len(regions) < 2 {
+		resp := p.send(workerReq{ctx: ctx, img: bytes.NewReader(imgBytes), opts: opts})
+		return resp.str, resp.err
+	}
+
+	texts := make([]string, len(regions))
+	progress := averageProgress{percents: make([]int32, len(regions)), cb: opts.ProgressCB}
+	group, groupCtx := errgroup.WithContext(ctx)
+	for i, region := range regions {
+		i, region := i, region
+		group.Go(func() error {
+			var regionImg bytes.Buffer
+			if err := png.Encode(&regionImg, page.SubImage(region)); err != nil {
+				return errors.Errorf("png.Encode %w", err)
+			}
+			regionOpts := opts
+			regionOpts.ProgressCB = progress.callback(i)
+			resp := p.send(workerReq{ctx: groupCtx, img: &regionImg, opts: regionOpts})
+			texts[i] = resp.str
+			return resp.err
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return "", errors.Errorf("parsing region failed due to %w", err)
+	}
+
+	// Tesseract separates blocks of text with an empty line, so the regions are separated the same way.
+	nonEmpty := texts[:0]
+	for _, text := range texts {
+		if text != "" {
+			nonEmpty = append(nonEmpty, text)
+		}
+	}
+	return strings.Join(nonEmpty, "\n"), nil
+}
+
+// groupLines merges consecutive lines into regions of roughly len(lines)/maxRegions lines, padded and clipped to bounds.
+// A line only joins a region if the grown region wouldn't overlap any line outside of it,
+// so multi column layouts don't get text recognized twice. For the same reason, a region is padded
+// by no more than half the gap to its nearest neighbor.
+func groupLines(lines []image.Rectangle, maxRegions int, bounds image.Rectangle) []image.Rectangle {
+	nonEmpty := make([]image.Rectangle, 0, len(lines))
+	for _, line := range lines {
+		if !line.Empty() {
+			nonEmpty = append(nonEmpty, line)
+		}
+	}
+	lines = nonEmpty
+	if len(lines) == 0 || maxRegions < 1 {
+		return nil
+	}
+
+	perRegion := (len(lines) + maxRegions - 1) / maxRegions
+	regions := make([]image.Rectangle, 0, maxRegions)
+	start, region := 0, lines[0]
+	for i := 1; i < len(lines); i++ {
+		grown := region.Union(lines[i])
+		if i-start < perRegion && !overlapsOtherLines(grown, lines, start, i) {
+			region = grown
+			continue
+		}
+		regions = append(regions, region)
+		start, region = i, lines[i]
+	}
+	regions = append(regions, region)
+
+	padded := make([]image.Rectangle, len(regions))
+	for i, region := range regions {
+		padding := regionPadding
+		for j, other := range regions {
+			if i != j {
+				padding = min(padding, rectGap(region, other)/2)
+			}
+		}
+		padded[i] = region.Inset(-max(0, padding)).Intersect(bounds)
+	}
+	return padded
+}
+
+// rectGap is the distance between two rectangles along the axis they're furthest apart on, or less than 1 if they touch.
+func rectGap(a, b image.Rectangle) int {
+	return max(b.Min.X-a.Max.X, a.Min.X-b.Max.X, b.Min.Y-a.Max.Y, a.Min.Y-b.Max.Y)
+}
+
+// overlapsOtherLines reports whether region overlaps any line outside of lines[start:end+1].
+func overlapsOtherLines(region image.Rectangle, lines []image.Rectangle, start, end int) bool {
+	for i, line := range lines {
+		if (i < start || i > end) && region.Overlaps(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// averageProgress averages the progress of several images into a single percentage for ParseImageOptions.ProgressCB.
+type averageProgress struct {
+	mu       sync.Mutex
+	percents []int32
+	cb       func(int32)
+}
+
+func (r *averageProgress) callback(i int) func(int32) {
+	if r.cb == nil {
+		return nil
+	}
+	return func(percent int32) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.percents[i] = percent
+		var total int32
+		for _, p := range r.percents {
+			total += p
+		}
+		r.cb(total / int32(len(r.percents)))
+	}
+}
@@ -0,0 +1,63 @@
+package gogosseract
+
+import (
+	"image"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGroupLines(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 200)
+	tests := []struct {
+		name       string
+		lines      []image.Rectangle
+		maxRegions int
+		want       []image.Rectangle
+	}{
+		{
+			"no lines",
+			nil,
+			4,
+			nil,
+		},
+		{
+			"single column split evenly",
+			[]image.Rectangle{
+				image.Rect(10, 10, 100, 20), image.Rect(10, 30, 100, 40),
+				image.Rect(10, 50, 100, 60), image.Rect(10, 70, 100, 80),
+			},
+			2,
+			[]image.Rectangle{image.Rect(6, 6, 104, 44), image.Rect(6, 46, 104, 84)},
+		},
+		{
+			"two columns stay apart",
+			[]image.Rectangle{
+				image.Rect(10, 10, 90, 20), image.Rect(10, 30, 90, 40),
+				image.Rect(110, 10, 190, 20), image.Rect(110, 30, 190, 40),
+			},
+			1,
+			[]image.Rectangle{image.Rect(6, 6, 94, 44), image.Rect(106, 6, 194, 44)},
+		},
+		{
+			"padding stops halfway to the next region",
+			[]image.Rectangle{image.Rect(10, 10, 100, 20), image.Rect(10, 23, 100, 33)},
+			2,
+			[]image.Rectangle{image.Rect(9, 9, 101, 21), image.Rect(9, 22, 101, 34)},
+		},
+		{
+			"padding clipped to bounds",
+			[]image.Rectangle{image.Rect(0, 0, 200, 20), image.Rect(0, 180, 200, 200)},
+			2,
+			[]image.Rectangle{image.Rect(0, 0, 200, 24), image.Rect(0, 176, 200, 200)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := groupLines(tt.lines, tt.maxRegions, bounds)
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Fatalf(diff)
+			}
+		})
+	}
+}
@@ -1,10 +1,16 @@
 package gogosseract
 
 import (
-	"bytes"
 	"context"
+	"fmt"
+	"image"
 	"io"
+	"runtime"
+	"runtime/debug"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/danlock/pkg/errors"
 	"github.com/tetratelabs/wazero"
@@ -15,7 +21,91 @@ type PoolConfig struct {
 	// TrainingDataBytes is Config.TrainingData, but as a []byte for concurrency's sake.
 	// Multiple Tesseract workers can't read from a single io.Reader, so they can't benefit from streaming the data.
 	// For convenience you only need to set either Config.TrainingData or TrainingDataBytes.
+	// The Pool keeps TrainingDataBytes for as long as it's open, since workers can be started at any time
+	// by recycling, autoscaling or replacing an interrupted worker.
 	TrainingDataBytes []byte
+	// TrainingDataReaderAt is the training data as an io.ReaderAt, like an *os.File or a file within an embed.FS.
+	// Workers stream it straight into WASM, so the Pool doesn't keep a copy of the training data in Go memory.
+	// It must stay readable until the Pool is closed. Its size comes from a Size or Stat method,
+	// so wrap any other io.ReaderAt with io.NewSectionReader. Takes precedence over TrainingDataBytes and Config.TrainingData.
+	// When the training data comes from Config.Tessdata, the Pool streams it from the file whenever it can.
+	TrainingDataReaderAt io.ReaderAt
+	// WASM memory grows but never shrinks, so the only way to release memory is closing a Tesseract worker and creating a new one.
+	// The Recycle options replace a worker once any of their limits are hit. The replacement is created in the background
+	// while the old worker keeps serving requests, so no requests are dropped. Zero values disable the limit.
+	//
+	// RecycleAfterImages recycles a worker after it has parsed this many images.
+	RecycleAfterImages uint
+	// RecycleAfterMemory recycles a worker once its WASM memory has grown past this many bytes.
+	RecycleAfterMemory uint64
+	// RecycleAfterAge recycles a worker once it has been running for this long.
+	RecycleAfterAge time.Duration
+	// StartupConcurrency is how many workers NewPool starts up at once, since loading the training data dominates startup time.
+	// Defaults to runtime.GOMAXPROCS.
+	StartupConcurrency uint
+	// MaxWorkers enables autoscaling when it's larger than NewPool's count, which becomes the starting amount of workers.
+	// Whenever a request waits longer than ScaleUpDelay for an available worker, another worker is started, up to MaxWorkers.
+	MaxWorkers uint
+	// MinWorkers is the amount of workers an autoscaling Pool shrinks down to. Defaults to NewPool's count.
+	MinWorkers uint
+	// IdleTimeout shuts down an autoscaling Pool's workers after they go this long without a request,
+	// as long as more than MinWorkers remain. Defaults to 5 minutes.
+	IdleTimeout time.Duration
+	// ScaleUpDelay is how long requests wait in the queue for an available worker before an autoscaling Pool starts another one.
+	// Defaults to 100 milliseconds.
+	ScaleUpDelay time.Duration
+	// MaxQueueDepth limits how many requests can wait for an available worker.
+	// Once it's reached ParseImage returns ErrPoolSaturated instead of waiting. Zero means unlimited.
+	MaxQueueDepth uint
+	// Languages holds training data for languages besides Config.Language, keyed by the language name given to Tesseract.
+	// Languages within Config.Tessdata are available too, without being listed here.
+	// Requests pick one with ParseImageOptions.Language. Every language shares the Pool's workers and queue.
+	// A request goes to an idle worker that already has its language loaded, or else an idle worker reloads
+	// with the request's language first, which takes about as long as starting up a worker.
+	Languages map[string][]byte
+	// RestartDelay is how long the Pool waits before trying again to start a worker that replaces a crashed or interrupted one.
+	// It doubles after every failed attempt, up to a minute. Defaults to 100 milliseconds.
+	RestartDelay time.Duration
+	// DeduplicateRequests makes identical requests in flight at the same time share a single worker's response.
+	// Requests are identical when their images hash the same, and they have the same IsHOCR, LoadImageOptions,
+	// Variables, Language, Priority and TenantID. Only the first request's ProgressCB is called. Images that can't be read twice,
+	// like an http.Request.Body, are buffered up front, which LoadImage would otherwise do later.
+	DeduplicateRequests bool
+	// Metrics receives the Pool's measurements as they happen, like how long requests wait and recognition takes.
+	// Pool.Stats keeps a snapshot of them regardless. ExpvarMetrics publishes them with the expvar package.
+	Metrics Metrics
+	// LanguageMemoryBudget is the most WASM memory in bytes the Pool's workers should use.
+	// Whenever a worker finishes a request over the budget, the least recently used idle workers with a language
+	// besides Config.Language loaded are replaced by fresh workers of Config.Language, until the Pool fits again.
+	// Zero disables this.
+	LanguageMemoryBudget uint64
+	// LanguageIdleTimeout replaces a worker with a language besides Config.Language loaded by a fresh worker of Config.Language,
+	// once it's gone this long without a request. Defaults to 5 minutes.
+	LanguageIdleTimeout time.Duration
+	// RetryPolicy retries requests that failed in a way another worker might not, like their worker crashing.
+	// By default requests aren't retried.
+	RetryPolicy RetryPolicy
+	// Tenants configures the share of the workers each ParseImageOptions.TenantID gets, so one tenant's bulk job can't starve the rest.
+	// Tenants missing from the map get the zero TenantConfig.
+	Tenants map[string]TenantConfig
+	// Interceptors wrap every image parsed by ParseImage, ParseImages, Submit and ParseImageParallel, along with every Do,
+	// the first being outermost.
+	// Requests they short-circuit never reach the workers, so they're left out of Stats and Metrics.
+	Interceptors []Interceptor
+	// WorkerProcesses runs every worker within a child process instead of this one, so a misbehaving image
+	// can't grow this process's memory, and a crashing worker can't take it down. See WorkerProcessConfig.
+	// Worker processes load the rest of a combined language like "eng+fra" from TESSDATA_PREFIX, not Config.Tessdata.
+	WorkerProcesses *WorkerProcessConfig
+}
+
+// ErrPoolSaturated is returned when a request is turned away because PoolConfig.MaxQueueDepth requests are already waiting.
+// Check for it with errors.As.
+type ErrPoolSaturated struct {
+	QueueDepth uint
+}
+
+func (e ErrPoolSaturated) Error() string {
+	return fmt.Sprintf("pool saturated with %d queued requests", e.QueueDepth)
 }
 
 // NewPool creates a pool of Tesseract clients for safe, efficient concurrent use.
@@ -23,41 +113,90 @@ func NewPool(ctx context.Context, count uint, cfg PoolConfig) (_ *Pool, err erro
 	if count == 0 {
 		return nil, errors.New("got zero count")
 	}
-	if cfg.TrainingDataBytes == nil && cfg.TrainingData == nil {
-		return nil, errors.New("requires either PoolConfig.TrainingDataBytes or Config.TrainingData")
+	if cfg.Language == "" {
+		cfg.Language = "eng"
 	}
-	if cfg.TrainingDataBytes == nil {
-		cfg.TrainingDataBytes, err = io.ReadAll(cfg.TrainingData)
-		if err != nil {
-			return nil, errors.Errorf("reading cfg.TrainingData failed because %w", err)
+	if cfg.MaxWorkers > 0 {
+		if cfg.MinWorkers == 0 {
+			cfg.MinWorkers = count
+		}
+		if cfg.MinWorkers > count || count > cfg.MaxWorkers {
+			return nil, errors.Errorf("requires PoolConfig.MinWorkers (%d) <= count (%d) <= PoolConfig.MaxWorkers (%d)", cfg.MinWorkers, count, cfg.MaxWorkers)
 		}
+		if cfg.IdleTimeout == 0 {
+			cfg.IdleTimeout = 5 * time.Minute
+		}
+		if cfg.ScaleUpDelay == 0 {
+			cfg.ScaleUpDelay = 100 * time.Millisecond
+		}
+	}
+	if cfg.LanguageIdleTimeout == 0 {
+		cfg.LanguageIdleTimeout = 5 * time.Minute
+	}
+	if cfg.RestartDelay == 0 {
+		cfg.RestartDelay = 100 * time.Millisecond
+	}
+	if cfg.RetryPolicy.Retryable == nil {
+		cfg.RetryPolicy.Retryable = IsRetryable
+	}
+	if cfg.RetryPolicy.MaxBackoff == 0 {
+		cfg.RetryPolicy.MaxBackoff = time.Minute
 	}
+	if cfg.StartupConcurrency == 0 {
+		cfg.StartupConcurrency = uint(runtime.GOMAXPROCS(0))
+	}
+	// Workers are interrupted when their request's context is done, since they can be replaced without the caller noticing.
+	cfg.Config.CloseOnContextDone = true
 	// Set WASMCache by default to speed up worker compilation
 	if cfg.Config.WASMCache == nil {
 		cfg.Config.WASMCache = wazero.NewCompilationCache()
 	}
+	m, err := newModel(ctx, &cfg)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	// The model holds onto the training data, the Pool shouldn't keep it after a reload.
+	cfg.TrainingData, cfg.TrainingDataBytes, cfg.TrainingDataReaderAt = nil, nil, nil
 	p := &Pool{
-		// errChan must be big enough for all workers to fail simultaneously
-		errChan: make(chan error, count),
-		reqChan: make(chan workerReq),
-		cfg:     cfg,
+		submitChan:     make(chan workerReq),
+		idleChan:       make(chan *worker),
+		leaveChan:      make(chan *worker),
+		wakeChan:       make(chan struct{}, 1),
+		cfg:            cfg,
+		workers:        make(map[*worker]struct{}),
+		languageModels: make(map[string]*model),
+		jobs:           make(map[uint64]*Job),
+		inflight:       make(map[dedupKey]*inflightCall),
+		model:          m,
 	}
+	p.metrics.hook = cfg.Metrics
 	p.ctx, p.shutdown = context.WithCancelCause(ctx)
 	ctx = p.ctx
+	p.wg.Add(1)
+	go p.dispatch(ctx)
+	// ready must be big enough for all workers to fail simultaneously
+	ready := make(chan error, count)
+	started := min(count, cfg.StartupConcurrency)
+	for i := uint(0); i < started; i++ {
+		p.startWorker(ready)
+	}
+	// Wait for every worker to start up, starting the next one as each finishes, and returning an error on any failure
 	for i := uint(0); i < count; i++ {
-		p.wg.Add(1)
-		// Synchronously startup workers, returning an error on any failure
-		go p.runTesseract(ctx)
 		select {
 		case <-ctx.Done():
+			_ = p.close(false)
 			return nil, errors.Errorf("timed out during worker setup due to %w", context.Cause(ctx))
-		case err := <-p.errChan:
+		case err := <-ready:
 			if err != nil {
 				// Disregard close() errors since err actually caused this.
 				// Further errors will just be an effect of the context cancellation.
 				_ = p.close(false)
 				return nil, errors.Errorf("failed worker setup due to %w", err)
 			}
+			if started < count {
+				p.startWorker(ready)
+				started++
+			}
 		}
 	}
 
@@ -68,13 +207,33 @@ type workerReq struct {
 	ctx  context.Context
 	img  io.Reader
 	opts ParseImageOptions
+	// layout requests the image's line boxes instead of its text
+	layout bool
+	// do leases the worker's Tesseract for Pool.Do instead of parsing img.
+	do func(context.Context, *Tesseract) error
+	// queued is when the request was sent to the dispatcher.
+	queued time.Time
+	// avoid is the worker a retried request just failed on, which the dispatcher won't hand it to again.
+	avoid *worker
+	// tenant is the ParseImageOptions.TenantID the request counts towards.
+	tenant *tenant
+	// rewind returns img from the start again, once it's been buffered for hashing. Retries reuse it.
+	rewind func() (io.Reader, error)
 
 	respChan chan workerResp
 }
 
 type workerResp struct {
-	str string
-	err error
+	str   string
+	rects []image.Rectangle
+	err   error
+	// modelID identifies the training data of the worker that responded, for Config.ResultCache.
+	modelID string
+	// worker is the worker that responded, if any.
+	worker *worker
+	// imageLoad and recognition are how long the worker took to load the image and recognize it, for Metrics.
+	imageLoad   time.Duration
+	recognition time.Duration
 }
 
 type Pool struct {
@@ -82,81 +241,732 @@ type Pool struct {
 	wg       sync.WaitGroup
 	cfg      PoolConfig
 	shutdown context.CancelCauseFunc
-	errChan  chan error
-	reqChan  chan workerReq
+	// submitChan sends requests to the dispatcher, which hands them out to the workers waiting on idleChan.
+	// Workers that stop waiting send themselves on leaveChan. wakeChan tells the dispatcher a tenant is no longer at its cap.
+	submitChan chan workerReq
+	idleChan   chan *worker
+	leaveChan  chan *worker
+	wakeChan   chan struct{}
+	// queueDepth is how many requests the dispatcher has queued.
+	queueDepth atomic.Uint64
+	metrics    poolMetrics
+	tenants    tenants
+
+	// closeErrs collects the errors from closing each worker's Tesseract.
+	closeErrs   []error
+	closeErrsMu sync.Mutex
+
+	// workers holds the workers serving requests, and starting counts the workers autoscaling is starting up.
+	workers   map[*worker]struct{}
+	starting  uint
+	workersMu sync.Mutex
+
+	// failed counts every worker failure, and failures holds the most recent ones for Health.
+	failed     uint
+	failures   []WorkerFailure
+	failuresMu sync.Mutex
+
+	// inflight holds the requests identical requests can wait on, with PoolConfig.DeduplicateRequests.
+	inflight   map[dedupKey]*inflightCall
+	inflightMu sync.Mutex
+
+	// outstanding counts the requests Shutdown waits for, and drained is closed once Shutdown has nothing left to wait for.
+	outstanding uint
+	drained     chan struct{}
+	drainMu     sync.Mutex
+
+	// jobs holds the unfinished Jobs from Submit by ID.
+	jobs   map[uint64]*Job
+	jobSeq atomic.Uint64
+	jobsMu sync.Mutex
+
+	// model is what new workers start up with. reloadMu stops ReloadModel's from overlapping.
+	model    *model
+	modelMu  sync.Mutex
+	reloadMu sync.Mutex
+	// languageModels holds the model of every other language that has been requested, guarded by modelMu.
+	// languagesMu serializes loading them.
+	languageModels map[string]*model
+	languagesMu    sync.Mutex
 }
 
-func (p *Pool) runTesseract(ctx context.Context) (err error) {
-	cfg := p.cfg.Config
-	cfg.TrainingData = bytes.NewBuffer(p.cfg.TrainingDataBytes)
-	tess, err := New(ctx, cfg)
-	defer func() {
-		if tess != nil {
-			err = errors.Join(err, tess.Close(ctx))
+// worker is the Pool's bookkeeping for a running Tesseract worker.
+type worker struct {
+	// id is the worker's WorkerMemory.ID.
+	id uint64
+	// memory is the size of the worker's WASM memory, updated after every request.
+	memory atomic.Uint64
+	// busy is true while the worker is parsing an image.
+	busy atomic.Bool
+	// modelMemory is the size of the worker's WASM memory right after loading the training data.
+	modelMemory uint64
+	// model is what the worker has loaded, guarded by Pool.workersMu. It changes when the worker reloads with another language.
+	model *model
+	// lastUsed is when the worker last finished a request, in Unix nanoseconds.
+	lastUsed atomic.Int64
+	// retire is closed to make the worker exit once it's done with its current request.
+	// retiring and evicting, which means a replacement of Config.Language is starting up, are guarded by Pool.workersMu.
+	retire   chan struct{}
+	retiring bool
+	evicting bool
+	// reqs receives the request the dispatcher hands the worker while it's idle.
+	reqs chan workerReq
+}
+
+// language is the language of the model the worker has loaded.
+func (w *worker) language() string {
+	if w.model == nil {
+		return ""
+	}
+	return w.model.cfg.Language
+}
+
+// WorkerMemory is the WASM memory of one of the Pool's workers, in bytes.
+type WorkerMemory struct {
+	// ID identifies the worker, and is unique among every Pool in the process.
+	ID uint64
+	// Model is the memory the worker used right after loading the training data, before parsing any images.
+	// Every worker has its own WASM memory, so each one pays this on its own.
+	Model uint64
+	// Current is the memory the worker uses now. WASM memory grows while parsing images but never shrinks.
+	Current uint64
+}
+
+// MemoryUsage reports the WASM memory of each of the Pool's workers.
+func (p *Pool) MemoryUsage() []WorkerMemory {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	usage := make([]WorkerMemory, 0, len(p.workers))
+	for w := range p.workers {
+		usage = append(usage, WorkerMemory{ID: w.id, Model: w.modelMemory, Current: w.memory.Load()})
+	}
+	return usage
+}
+
+// memoryUsage returns the total WASM memory of every worker in bytes.
+func (p *Pool) memoryUsage() (total uint64) {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	for w := range p.workers {
+		total += w.memory.Load()
+	}
+	return total
+}
+
+// workerCount returns the amount of workers currently serving requests.
+func (p *Pool) workerCount() uint {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	return uint(len(p.workers))
+}
+
+// capacity returns the most workers the Pool could serve requests with.
+func (p *Pool) capacity() uint {
+	if p.isAutoscaling() {
+		return p.cfg.MaxWorkers
+	}
+	return p.workerCount()
+}
+
+// isAutoscaling reports whether the Pool is allowed to start and stop workers on its own.
+func (p *Pool) isAutoscaling() bool {
+	return p.cfg.MaxWorkers > 0
+}
+
+// scaleUp starts another worker unless the Pool is already at PoolConfig.MaxWorkers.
+func (p *Pool) scaleUp() {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	if uint(len(p.workers))+p.starting >= p.cfg.MaxWorkers {
+		return
+	}
+	p.starting++
+	ready := make(chan error, 1)
+	p.startWorker(ready)
+	go func() {
+		// Failures are ignored, since the next request left waiting will just try again.
+		<-ready
+		p.workersMu.Lock()
+		p.starting--
+		p.workersMu.Unlock()
+	}()
+}
+
+// scaleDown removes an idle worker, unless that would leave the Pool with less than PoolConfig.MinWorkers.
+func (p *Pool) scaleDown(w *worker) bool {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	if uint(len(p.workers)) <= p.cfg.MinWorkers {
+		return false
+	}
+	delete(p.workers, w)
+	return true
+}
+
+// startWorker runs a new Tesseract worker in the background.
+// ready receives nil once the worker is serving requests, or the error that prevented it from starting.
+// If the worker panics after starting up, the failure is recorded and the worker is replaced.
+func (p *Pool) startWorker(ready chan<- error) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		var started bool
+		defer func() {
+			if r := recover(); r != nil {
+				err := errors.Errorf("worker panicked with %v\n%s", r, debug.Stack())
+				if !started {
+					ready <- err
+					return
+				}
+				p.recordFailure(err)
+				p.replaceWorker()
+			}
+		}()
+		if err := p.runTesseract(p.ctx, func(err error) { started = err == nil; ready <- err }); err != nil {
+			p.closeErrsMu.Lock()
+			p.closeErrs = append(p.closeErrs, err)
+			p.closeErrsMu.Unlock()
 		}
-		// defer sending on errChan so we don't have to do it each return
-		p.errChan <- err
-		p.wg.Done()
 	}()
+}
+
+// workerIDs hands out WorkerMemory.ID, shared by every Pool so Metrics shared between Pools can tell workers apart.
+var workerIDs atomic.Uint64
+
+// engine is what a worker handles requests with, either a Tesseract or a worker process running one.
+type engine interface {
+	// handle runs a single request, interrupted once ctx is done.
+	handle(ctx context.Context, req workerReq) workerResp
+	memorySize() uint64
+	isClosed() bool
+	// exited is closed if the engine dies on its own, like a worker process killed by the OS.
+	exited() <-chan struct{}
+	Close(ctx context.Context) error
+}
+
+// startEngine starts up an engine with m, within a worker process if PoolConfig.WorkerProcesses is set.
+func (p *Pool) startEngine(ctx context.Context, m *model) (engine, error) {
+	if p.cfg.WorkerProcesses != nil {
+		proc, err := startProcess(ctx, *p.cfg.WorkerProcesses, m.cfg, m.trainingData())
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		return proc, nil
+	}
+	cfg := m.cfg
+	cfg.TrainingData = m.trainingData()
+	tess, err := New(ctx, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return tess, nil
+}
+
+// loadEngine starts up an engine with lang's model.
+func (p *Pool) loadEngine(ctx context.Context, lang string) (engine, *model, error) {
+	m, loaded := p.acquireModel(lang)
+	loadStart := time.Now()
+	eng, err := p.startEngine(ctx, m)
+	loaded()
+	if err != nil {
+		return nil, nil, errors.Wrap(err)
+	}
+	p.metrics.ModelLoad(time.Since(loadStart))
+	return eng, m, nil
+}
 
+func (p *Pool) runTesseract(ctx context.Context, ready func(error)) (err error) {
+	eng, m, err := p.loadEngine(ctx, p.cfg.Language)
 	if err != nil {
-		return errors.Wrap(err)
+		ready(errors.Wrap(err))
+		return nil
+	}
+	w := &worker{id: workerIDs.Add(1), modelMemory: eng.memorySize(), model: m, retire: make(chan struct{}), reqs: make(chan workerReq, 1)}
+	w.memory.Store(w.modelMemory)
+	w.lastUsed.Store(time.Now().UnixNano())
+	p.metrics.WorkerMemory(WorkerMemory{ID: w.id, Model: w.modelMemory, Current: w.modelMemory})
+	p.workersMu.Lock()
+	p.workers[w] = struct{}{}
+	p.workersMu.Unlock()
+	defer func() {
+		p.workersMu.Lock()
+		delete(p.workers, w)
+		p.workersMu.Unlock()
+		p.metrics.WorkerMemory(WorkerMemory{ID: w.id})
+		// ctx is usually done by now, which would interrupt Close thanks to Config.CloseOnContextDone.
+		err = errors.Join(err, eng.Close(context.WithoutCancel(ctx)))
+	}()
+	// Send back a nil so whoever started us knows this worker's ready to receive requests
+	ready(nil)
+
+	started := time.Now()
+	var images uint
+	var ageTimer <-chan time.Time
+	if p.cfg.RecycleAfterAge > 0 {
+		timer := time.NewTimer(p.cfg.RecycleAfterAge)
+		defer timer.Stop()
+		ageTimer = timer.C
+	}
+	var idleTimer *time.Timer
+	var idle <-chan time.Time
+	if p.isAutoscaling() {
+		idleTimer = time.NewTimer(p.cfg.IdleTimeout)
+		defer idleTimer.Stop()
+		idle = idleTimer.C
+	}
+	// languageIdle fires once the worker has gone LanguageIdleTimeout without a request, while it has another language loaded.
+	var languageIdleTimer *time.Timer
+	var languageIdle <-chan time.Time
+	defer func() {
+		if languageIdleTimer != nil {
+			languageIdleTimer.Stop()
+		}
+	}()
+	// replacement is non nil while this worker's replacement is starting up.
+	var replacement chan error
+	recycle := func() {
+		if replacement == nil {
+			replacement = make(chan error, 1)
+			p.startWorker(replacement)
+		}
 	}
-	// Send back a nil so NewPool knows this worker's ready to receive requests
-	p.errChan <- nil
+
+	// waiting is true while the dispatcher counts this worker as idle, and may hand it a request at any moment.
+	var waiting bool
+	defer func() {
+		if waiting {
+			p.leaveIdle(w)
+		}
+	}()
 
 	for {
+		var idleChan chan<- *worker
+		var reqs <-chan workerReq
+		if waiting {
+			reqs = w.reqs
+		} else {
+			idleChan = p.idleChan
+		}
 		select {
 		case <-ctx.Done():
 			return nil
-		case req := <-p.reqChan:
-			if err := tess.LoadImage(req.ctx, req.img, req.opts.LoadImageOptions); err != nil {
-				req.respChan <- workerResp{err: errors.Errorf(" %w", err)}
-				continue
+		case idleChan <- w:
+			waiting = true
+		case <-w.retire:
+			return nil
+		case <-ageTimer:
+			recycle()
+		case <-idle:
+			if p.scaleDown(w) {
+				return nil
+			}
+			idleTimer.Reset(p.cfg.IdleTimeout)
+		case <-languageIdle:
+			languageIdle = nil
+			p.evictWorker(w)
+		case <-eng.exited():
+			// The engine died while idle, like a worker process killed for using too much memory.
+			// Replace it now, instead of failing the next request handed to it.
+			p.recordFailure(errors.Errorf("%w", ErrWorkerFailed{Err: errors.New("worker process exited while idle")}))
+			p.workersMu.Lock()
+			delete(p.workers, w)
+			p.workersMu.Unlock()
+			if ctx.Err() == nil {
+				p.replaceAfter(replacement)
+			}
+			return nil
+		case err := <-replacement:
+			if err == nil {
+				// The replacement is serving requests, so this worker can retire.
+				return nil
+			}
+			// The replacement failed to start, so keep serving and try again on the next request.
+			replacement = nil
+		case req := <-reqs:
+			waiting = false
+			w.busy.Store(true)
+			if lang := req.opts.Language; lang != w.language() {
+				// No idle worker had the request's language loaded, so this one reloads with it.
+				next, m, err := p.loadEngine(ctx, lang)
+				if err != nil {
+					err = errors.Errorf("loading %s failed due to %w", lang, err)
+					req.tenant.stop(true, err)
+					p.wake()
+					req.respChan <- workerResp{err: err, worker: w}
+					w.busy.Store(false)
+					continue
+				}
+				if err := eng.Close(context.WithoutCancel(ctx)); err != nil {
+					p.closeErrsMu.Lock()
+					p.closeErrs = append(p.closeErrs, errors.Errorf("closing %s worker %w", w.language(), err))
+					p.closeErrsMu.Unlock()
+				}
+				eng = next
+				p.workersMu.Lock()
+				w.model, w.modelMemory = m, eng.memorySize()
+				p.workersMu.Unlock()
+				w.memory.Store(w.modelMemory)
+				images, started = 0, time.Now()
 			}
-			var resp workerResp
-			if req.opts.IsHOCR {
-				resp.str, resp.err = tess.GetHOCR(ctx, req.opts.ProgressCB)
-			} else {
-				resp.str, resp.err = tess.GetText(ctx, req.opts.ProgressCB)
+			resp := p.parse(ctx, eng, req)
+			resp.modelID, resp.worker = w.model.id, w
+			req.tenant.stop(true, resp.err)
+			p.wake()
+			closed := eng.isClosed()
+			if closed {
+				// Stop counting this worker before responding, so a retry knows it's being replaced.
+				p.workersMu.Lock()
+				delete(p.workers, w)
+				p.workersMu.Unlock()
 			}
 			req.respChan <- resp
+			w.busy.Store(false)
+			if closed {
+				// The request was interrupted or retired this Tesseract, leaving it unusable. Replace it, unless we already are.
+				p.replaceAfter(replacement)
+				return nil
+			}
 			// We could clear the image in advance to release the memory but unfortunately...
 			// WASM memory grows but doesn't shrink, so that won't reduce memory usage.
-			// The only way to release memory is closing a Tesseract client and creating a new one...
+			// The only way to release memory is closing a Tesseract client and creating a new one.
+			images++
+			w.memory.Store(eng.memorySize())
+			w.lastUsed.Store(time.Now().UnixNano())
+			p.metrics.WorkerMemory(WorkerMemory{ID: w.id, Model: w.modelMemory, Current: w.memory.Load()})
+			if p.shouldRecycle(w.memory.Load(), images, started) {
+				recycle()
+			}
+			if languageIdleTimer != nil {
+				languageIdleTimer.Stop()
+			}
+			languageIdleTimer, languageIdle = nil, nil
+			if w.language() != p.cfg.Language {
+				languageIdleTimer = time.NewTimer(p.cfg.LanguageIdleTimeout)
+				languageIdle = languageIdleTimer.C
+			}
+			p.enforceLanguageBudget()
+			if idleTimer != nil {
+				if !idleTimer.Stop() {
+					select {
+					case <-idleTimer.C:
+					default:
+					}
+				}
+				idleTimer.Reset(p.cfg.IdleTimeout)
+			}
 		}
 	}
 }
 
+// parse runs a single request on a worker's engine.
+// The engine is interrupted if either the request's context or the Pool's context is done.
+func (p *Pool) parse(poolCtx context.Context, eng engine, req workerReq) workerResp {
+	if err := req.ctx.Err(); err != nil {
+		// The caller gave up while the request was queued, so don't bother.
+		return workerResp{err: errors.Errorf("while queued %w", context.Cause(req.ctx))}
+	}
+	p.metrics.QueueWait(time.Since(req.queued))
+	if req.tenant != nil {
+		req.tenant.queueWait.observe(time.Since(req.queued))
+	}
+	ctx, cancel := context.WithCancelCause(req.ctx)
+	defer cancel(nil)
+	stop := context.AfterFunc(poolCtx, func() { cancel(context.Cause(poolCtx)) })
+	defer stop()
+
+	resp := eng.handle(ctx, req)
+	if errors.As(resp.err, &ErrWorkerFailed{}) {
+		p.recordFailure(resp.err)
+	}
+	if resp.imageLoad > 0 {
+		p.metrics.ImageLoad(resp.imageLoad)
+	}
+	if resp.recognition > 0 {
+		p.metrics.Recognition(resp.recognition)
+	}
+	return resp
+}
+
+// handle runs a single request on the Tesseract of a worker, which may be within a worker process.
+func (t *Tesseract) handle(ctx context.Context, req workerReq) (resp workerResp) {
+	defer func() {
+		// A trap or panic leaves t unusable, so retire it for the worker to be replaced.
+		var panicked bool
+		if r := recover(); r != nil {
+			resp, panicked = workerResp{err: errors.Errorf("panicked with %v", r)}, true
+		}
+		if panicked || isTrap(resp.err) {
+			resp.err = errors.Errorf("%w", ErrWorkerFailed{Err: t.retire(resp.err)})
+		}
+	}()
+
+	if req.do != nil {
+		return workerResp{err: t.lease(ctx, req.do)}
+	}
+
+	if len(req.opts.Variables) > 0 {
+		restore, err := t.overrideVariables(ctx, req.opts.Variables)
+		if restore != nil {
+			defer func() {
+				if t.isClosed() {
+					return
+				}
+				// Restore even if ctx is done, otherwise the overrides would leak into the next request.
+				if err := restore(context.WithoutCancel(ctx)); err != nil {
+					resp.err = errors.Join(resp.err, errors.Errorf("restoring variables %w", t.retire(err)))
+				}
+			}()
+		}
+		if err != nil {
+			return workerResp{err: errors.Errorf("overriding variables %w", err)}
+		}
+	}
+
+	loadStart := time.Now()
+	if err := t.LoadImage(ctx, req.img, req.opts.LoadImageOptions); err != nil {
+		return workerResp{err: errors.Errorf(" %w", err)}
+	}
+	resp.imageLoad = time.Since(loadStart)
+	recognitionStart := time.Now()
+	switch {
+	case req.layout:
+		resp.rects, resp.err = t.GetLineBoxes(ctx)
+	case req.opts.IsHOCR:
+		resp.str, resp.err = t.GetHOCR(ctx, req.opts.ProgressCB)
+	default:
+		resp.str, resp.err = t.GetText(ctx, req.opts.ProgressCB)
+	}
+	if resp.err != nil && ctx.Err() != nil {
+		resp.err = errors.Errorf("interrupted due to %w", errors.Join(context.Cause(ctx), resp.err))
+	} else if resp.err == nil {
+		resp.recognition = time.Since(recognitionStart)
+	}
+	return resp
+}
+
+// retire closes t after it failed in a way that would leak state into the next request, like failing to restore its variables.
+// The worker notices t is closed and replaces itself.
+func (t *Tesseract) retire(err error) error {
+	return errors.Join(err, t.module.Close(context.Background()))
+}
+
+// shouldRecycle reports whether a worker using memory bytes of WASM memory has hit any of the PoolConfig Recycle limits.
+func (p *Pool) shouldRecycle(memory uint64, images uint, started time.Time) bool {
+	cfg := p.cfg
+	return (cfg.RecycleAfterImages > 0 && images >= cfg.RecycleAfterImages) ||
+		(cfg.RecycleAfterMemory > 0 && memory > cfg.RecycleAfterMemory) ||
+		(cfg.RecycleAfterAge > 0 && time.Since(started) >= cfg.RecycleAfterAge)
+}
+
 type ParseImageOptions struct {
 	LoadImageOptions
 	// IsHOCR makes a GetHOCR request instead of the default GetText
 	IsHOCR bool
 	// Called whenever Tesseract's parsing progresses, gives a percentage.
 	ProgressCB func(int32)
+	// Variables overrides Tesseract config variables for this request only, like {"tessedit_pageseg_mode": "7"}
+	// to parse the image as a single line of text. The worker restores its previous values afterwards.
+	Variables map[string]string
+	// Language picks which of PoolConfig.Languages parses the image. Defaults to Config.Language.
+	Language string
+	// Priority orders requests waiting for an available worker. Higher priorities are handled first,
+	// and requests of equal priority are handled in the order they arrived, unless they're from different tenants.
+	Priority int
+	// TenantID is who the request is for. Requests of equal Priority are shared between tenants by weighted fair queuing,
+	// and each tenant can be limited to a number of workers at once, as configured by PoolConfig.Tenants.
+	// Pool.Stats breaks down requests by tenant too. Requests without a TenantID are a tenant of their own.
+	TenantID string
 }
 
 // ParseImage loads an image into our Tesseract object and gets back text from it.
 // Both actions are executed on an available worker.
 // Set a timeout with context.WithTimeout to handle the case where all workers are busy.
 func (p *Pool) ParseImage(ctx context.Context, img io.Reader, opts ParseImageOptions) (string, error) {
-	req := workerReq{ctx: ctx, img: img, opts: opts, respChan: make(chan workerResp, 1)}
+	done, err := p.track(1)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	defer done()
+	return p.parseImage(ctx, img, opts)
+}
+
+// send queues req for an available worker and waits for its response.
+func (p *Pool) send(req workerReq) workerResp {
+	if req.opts.Language == "" {
+		req.opts.Language = p.cfg.Language
+	}
+	if err := p.loadLanguage(req.ctx, req.opts.Language); err != nil {
+		err = errors.Wrap(err)
+		p.metrics.RequestDone(err)
+		return workerResp{err: err}
+	}
+	cacheable := p.cfg.ResultCache != nil && req.do == nil && !req.layout
+	if req.do != nil || !cacheable && !p.cfg.DeduplicateRequests {
+		return p.route(req)
+	}
+	img, rewind, err := hashReader(req.ctx, &req.img)
+	req.rewind = rewind
+	if err != nil {
+		// Let the request fail on its own.
+		return p.route(req)
+	}
+
+	opts := optionsKey(req.opts.Language, req.opts.IsHOCR, req.opts.LoadImageOptions, false, req.opts.Variables)
+	if cacheable {
+		if text, ok := p.cfg.ResultCache.Get(resultKey(img, p.modelID(req.opts.Language), opts)); ok {
+			p.metrics.ResultCache(true)
+			p.metrics.RequestDone(nil)
+			if req.opts.ProgressCB != nil {
+				req.opts.ProgressCB(100)
+			}
+			return workerResp{str: text}
+		}
+		p.metrics.ResultCache(false)
+	}
+	var resp workerResp
+	if p.cfg.DeduplicateRequests {
+		resp = p.sendDeduplicated(p.dedupKey(img, req), req)
+	} else {
+		resp = p.route(req)
+	}
+	if cacheable && resp.err == nil {
+		// Store it under the training data that recognized it, since ReloadModel may have swapped it in the meantime.
+		p.cfg.ResultCache.Set(resultKey(img, resp.modelID, opts), resp.str)
+	}
+	return resp
+}
+
+// route sends req to the workers and records how it went.
+func (p *Pool) route(req workerReq) workerResp {
+	resp := p.queue(req)
+	p.metrics.RequestDone(resp.err)
+	return resp
+}
+
+// queue hands req to the dispatcher and waits for a worker's response.
+func (p *Pool) queue(req workerReq) workerResp {
+	if p.cfg.RetryPolicy.MaxAttempts > 1 && req.do == nil {
+		return p.retry(req)
+	}
+	return p.queueOnce(req)
+}
+
+// queueOnce hands req to the dispatcher once and waits for a worker's response.
+func (p *Pool) queueOnce(req workerReq) workerResp {
+	ctx := req.ctx
+	req.respChan = make(chan workerResp, 1)
+	req.queued = time.Now()
+	req.tenant = p.tenants.get(req.opts.TenantID, p.cfg.Tenants)
+	defer p.tenants.put(req.tenant)
 
 	select {
 	case <-p.ctx.Done():
-		return "", errors.Errorf("while waiting for available worker %w", context.Cause(p.ctx))
+		return workerResp{err: errors.Errorf("while queueing request %w", context.Cause(p.ctx))}
 	case <-ctx.Done():
-		return "", errors.Errorf("while waiting for available worker %w", context.Cause(ctx))
-	case p.reqChan <- req:
+		return workerResp{err: errors.Errorf("while queueing request %w", context.Cause(ctx))}
+	case p.submitChan <- req:
 	}
 	// with respChan buffered, even if we time out early the worker will send their resp without blocking forever
 	select {
 	case <-p.ctx.Done():
-		return "", errors.Errorf("while waiting for worker's response %w", context.Cause(p.ctx))
+		return workerResp{err: errors.Errorf("while waiting for worker's response %w", context.Cause(p.ctx))}
 	case <-ctx.Done():
-		return "", errors.Errorf("while waiting for worker's response %w", context.Cause(ctx))
+		return workerResp{err: errors.Errorf("while waiting for worker's response %w", context.Cause(ctx))}
 	case resp := <-req.respChan:
-		return resp.str, resp.err
+		return resp
+	}
+}
+
+// dispatch queues requests by priority and hands them off to workers as they become available.
+// While requests are left waiting for ScaleUpDelay, an autoscaling Pool starts up more workers.
+func (p *Pool) dispatch(ctx context.Context) {
+	defer p.wg.Done()
+	var queue requestQueue
+	// idle holds the workers waiting for a request, longest waiting first.
+	var idle []*worker
+	var scaleUpTimer *time.Timer
+	defer func() {
+		if scaleUpTimer != nil {
+			scaleUpTimer.Stop()
+		}
+	}()
+
+	for {
+		idle = assignRequests(&queue, idle)
+		p.queueDepth.Store(uint64(queue.Len()))
+		var scaleUp <-chan time.Time
+		if p.isAutoscaling() && queue.Len() > 0 {
+			if scaleUpTimer == nil {
+				scaleUpTimer = time.NewTimer(p.cfg.ScaleUpDelay)
+			}
+			scaleUp = scaleUpTimer.C
+		} else if scaleUpTimer != nil {
+			scaleUpTimer.Stop()
+			scaleUpTimer = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-p.submitChan:
+			if maxDepth := p.cfg.MaxQueueDepth; maxDepth > 0 && uint(queue.Len()) >= maxDepth {
+				queue.prune()
+				if uint(queue.Len()) >= maxDepth {
+					req.respChan <- workerResp{err: errors.Errorf("%w", ErrPoolSaturated{QueueDepth: uint(queue.Len())})}
+					continue
+				}
+			}
+			queue.push(req)
+		case w := <-p.idleChan:
+			idle = append(idle, w)
+		case w := <-p.leaveChan:
+			idle = slices.DeleteFunc(idle, func(idler *worker) bool { return idler == w })
+			// Requeue the request w may have been handed before it left.
+			select {
+			case req := <-w.reqs:
+				req.tenant.stop(false, nil)
+				queue.push(req)
+			default:
+			}
+		case <-p.wakeChan:
+		case <-scaleUp:
+			// The queue hasn't emptied out in ScaleUpDelay, so we need another worker.
+			scaleUpTimer = nil
+			p.scaleUp()
+		}
+	}
+}
+
+// assignRequests hands queued requests to the idle workers in the queue's order, returning the workers left idle.
+// Each request goes to the idle worker that waited longest among those with its language loaded,
+// or else to the one that waited longest overall, which reloads with the request's language. Requests never go to the worker they avoid.
+func assignRequests(queue *requestQueue, idle []*worker) []*worker {
+	for len(idle) > 0 && queue.Len() > 0 {
+		req, i, ok := queue.popFor(idle)
+		if !ok {
+			break
+		}
+		req.tenant.start()
+		// reqs is buffered and the worker waits for a single request at a time, so this never blocks.
+		idle[i].reqs <- req
+		idle = slices.Delete(idle, i, i+1)
+	}
+	return idle
+}
+
+// wake tells the dispatcher to check again for requests it can hand out, without waiting if it's already been told.
+func (p *Pool) wake() {
+	select {
+	case p.wakeChan <- struct{}{}:
+	default:
+	}
+}
+
+// leaveIdle tells the dispatcher w is no longer waiting for a request.
+func (p *Pool) leaveIdle(w *worker) {
+	select {
+	case p.leaveChan <- w:
+	case <-p.ctx.Done():
 	}
 }
 
@@ -167,15 +977,20 @@ func (p *Pool) Close() error {
 }
 
 func (p *Pool) close(getErrors bool) error {
-	p.shutdown(errors.New(""))
+	p.shutdown(errors.New("the Pool was closed"))
+	var errs []error
+	// Wait for any ReloadModel to give up, since it starts workers of its own.
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
 	p.wg.Wait()
+	if err := p.swapModel(nil).close(); err != nil {
+		errs = append(errs, errors.Errorf("closing training data %w", err))
+	}
+	errs = append(errs, p.closeLanguages()...)
 	if !getErrors {
 		return nil
 	}
-	// errChan was made big enough to hold an error or a nil from each worker
-	workerErrors := make([]error, cap(p.errChan))
-	for i := range workerErrors {
-		workerErrors[i] = <-p.errChan
-	}
-	return errors.Join(workerErrors...)
+	p.closeErrsMu.Lock()
+	defer p.closeErrsMu.Unlock()
+	return errors.Join(append(p.closeErrs, errs...)...)
 }
@@ -4,10 +4,16 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/danlock/gogosseract"
+	"github.com/danlock/pkg/errors"
 	"github.com/danlock/pkg/test"
 )
 
@@ -66,3 +72,597 @@ func TestPool(t *testing.T) {
 		t.Fatalf("pool.ParseImage didn't return error")
 	}
 }
+
+func TestPool_Health(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := gogosseract.NewPool(ctx, 3, gogosseract.PoolConfig{TrainingDataBytes: engTrainedData})
+	test.FailOnError(t, err)
+	defer pool.Close()
+	if health := pool.Health(); health.Live != 3 || health.Busy != 0 || health.Failed != 0 {
+		t.Fatalf("Pool.Health returned unexpected %+v", health)
+	}
+}
+
+func TestPool_Stats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := gogosseract.NewPool(ctx, 2, gogosseract.PoolConfig{TrainingDataBytes: engTrainedData})
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	images := [...][]byte{logoImg, docsImg, logoImg}
+	for _, img := range images {
+		_, err := pool.ParseImage(ctx, bytes.NewBuffer(img), gogosseract.ParseImageOptions{})
+		test.FailOnError(t, err)
+	}
+	if _, err = pool.ParseImage(ctx, nil, gogosseract.ParseImageOptions{}); err == nil {
+		t.Fatalf("pool.ParseImage didn't return error")
+	}
+
+	stats := pool.Stats()
+	if stats.Requests != uint64(len(images))+1 || stats.Errors != 1 || stats.Recognition.Count != uint64(len(images)) ||
+		stats.ModelLoad.Count != 2 || len(stats.Workers) != 2 || stats.QueueDepth != 0 {
+		t.Fatalf("Pool.Stats returned unexpected %+v", stats)
+	}
+}
+
+func TestPool_ParseImageParallel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := gogosseract.NewPool(ctx, 3, gogosseract.PoolConfig{TrainingDataBytes: engTrainedData})
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	text, err := pool.ParseImageParallel(ctx, bytes.NewBuffer(docsImg), gogosseract.ParseImageOptions{})
+	test.FailOnError(t, err)
+	for _, want := range []string{"Request body", "wifiAccessPoints", "fallbacks"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("Pool.ParseImageParallel text missing %s, got %s", want, text)
+		}
+	}
+
+	if _, err = pool.ParseImageParallel(ctx, bytes.NewBuffer(docsImg), gogosseract.ParseImageOptions{IsHOCR: true}); err == nil {
+		t.Fatalf("Pool.ParseImageParallel should have failed with IsHOCR")
+	}
+}
+
+func TestPool_Recycle(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := gogosseract.NewPool(ctx, 2, gogosseract.PoolConfig{
+		TrainingDataBytes:  engTrainedData,
+		RecycleAfterImages: 1,
+		RecycleAfterMemory: 1,
+		RecycleAfterAge:    time.Millisecond,
+	})
+	test.FailOnError(t, err)
+
+	for i := 0; i < 5; i++ {
+		text, err := pool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{})
+		test.FailOnError(t, err)
+		if text != logoText {
+			t.Fatalf("Pool.ParseImage returned unexpected text %s", text)
+		}
+	}
+	test.FailOnError(t, pool.Close())
+}
+
+func TestPool_Autoscale(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := gogosseract.NewPool(ctx, 3, gogosseract.PoolConfig{TrainingDataBytes: engTrainedData, MaxWorkers: 2})
+	if err == nil {
+		t.Fatalf("gogosseract.NewPool should have failed with count > MaxWorkers")
+	}
+
+	pool, err := gogosseract.NewPool(ctx, 1, gogosseract.PoolConfig{
+		TrainingDataBytes: engTrainedData,
+		MaxWorkers:        3,
+		IdleTimeout:       50 * time.Millisecond,
+		ScaleUpDelay:      time.Millisecond,
+	})
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	for round := 0; round < 2; round++ {
+		textChan := make(chan string, 6)
+		for i := 0; i < cap(textChan); i++ {
+			go func() {
+				text, err := pool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{})
+				if err != nil {
+					panic(err)
+				}
+				textChan <- text
+			}()
+		}
+		for i := 0; i < cap(textChan); i++ {
+			select {
+			case <-ctx.Done():
+				t.Fatal("timed out waiting for Pool.ParseImage")
+			case r := <-textChan:
+				if r != logoText {
+					t.Fatalf("Pool.ParseImage returned unexpected text %s", r)
+				}
+			}
+		}
+		// Give the extra workers time to idle out before the next round scales up again.
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestNewPool_StartupConcurrency(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tests := []struct {
+		name        string
+		concurrency uint
+		trainedData []byte
+		wantErr     bool
+	}{
+		{"sequential", 1, engTrainedData, false},
+		{"concurrent", 3, engTrainedData, false},
+		{"more concurrency than workers", 10, engTrainedData, false},
+		{"concurrent failure", 3, []byte{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := gogosseract.NewPool(ctx, 3, gogosseract.PoolConfig{
+				TrainingDataBytes:  tt.trainedData,
+				StartupConcurrency: tt.concurrency,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("gogosseract.NewPool() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			text, err := pool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{})
+			test.FailOnError(t, err)
+			if text != logoText {
+				t.Fatalf("Pool.ParseImage returned unexpected text %s", text)
+			}
+			test.FailOnError(t, pool.Close())
+		})
+	}
+}
+
+func TestPool_MaxQueueDepth(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := gogosseract.NewPool(ctx, 1, gogosseract.PoolConfig{
+		TrainingDataBytes: engTrainedData,
+		MaxQueueDepth:     1,
+	})
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	errChan := make(chan error, 10)
+	for i := 0; i < cap(errChan); i++ {
+		go func(i int) {
+			text, err := pool.ParseImage(ctx, bytes.NewBuffer(docsImg), gogosseract.ParseImageOptions{Priority: i})
+			if err == nil && text != docsText {
+				err = errors.Errorf("Pool.ParseImage returned unexpected text %s", text)
+			}
+			errChan <- err
+		}(i)
+	}
+
+	var saturated int
+	for i := 0; i < cap(errChan); i++ {
+		select {
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for Pool.ParseImage")
+		case err := <-errChan:
+			if errors.As(err, &gogosseract.ErrPoolSaturated{}) {
+				saturated++
+			} else {
+				test.FailOnError(t, err)
+			}
+		}
+	}
+	if saturated == 0 {
+		t.Fatalf("Pool.ParseImage never returned ErrPoolSaturated")
+	}
+}
+
+func TestPool_Interrupt(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := gogosseract.NewPool(ctx, 1, gogosseract.PoolConfig{TrainingDataBytes: engTrainedData})
+	test.FailOnError(t, err)
+
+	reqCtx, reqCancel := context.WithCancel(ctx)
+	_, err = pool.ParseImage(reqCtx, bytes.NewBuffer(docsImg), gogosseract.ParseImageOptions{
+		// Give up as soon as Tesseract starts recognizing text.
+		ProgressCB: func(int32) { reqCancel() },
+	})
+	if err == nil {
+		t.Fatalf("Pool.ParseImage should have been interrupted")
+	}
+	// The interrupted worker gets replaced, so the Pool keeps working.
+	text, err := pool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{})
+	test.FailOnError(t, err)
+	if text != logoText {
+		t.Fatalf("Pool.ParseImage returned unexpected text %s", text)
+	}
+	test.FailOnError(t, pool.Close())
+}
+
+func TestPool_Variables(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := gogosseract.NewPool(ctx, 1, gogosseract.PoolConfig{TrainingDataBytes: engTrainedData})
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	text, err := pool.ParseImage(ctx, bytes.NewBuffer(docsImg), gogosseract.ParseImageOptions{
+		Variables: map[string]string{"tessedit_char_whitelist": "0123456789"},
+	})
+	test.FailOnError(t, err)
+	if strings.ContainsAny(text, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") {
+		t.Fatalf("Pool.ParseImage ignored the whitelist and returned %s", text)
+	}
+
+	_, err = pool.ParseImage(ctx, bytes.NewBuffer(docsImg), gogosseract.ParseImageOptions{
+		Variables: map[string]string{"asdf": "qwer"},
+	})
+	if err == nil {
+		t.Fatalf("Pool.ParseImage should have failed with an unknown variable")
+	}
+
+	// The same worker handles the next request, which shouldn't be affected by the previous overrides.
+	text, err = pool.ParseImage(ctx, bytes.NewBuffer(docsImg), gogosseract.ParseImageOptions{})
+	test.FailOnError(t, err)
+	if text != docsText {
+		t.Fatalf("Pool.ParseImage returned unexpected text %s", text)
+	}
+}
+
+func TestPool_Languages(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// Tesseract accepts any language name as long as the training data matches, so eng stands in for other languages.
+	pool, err := gogosseract.NewPool(ctx, 1, gogosseract.PoolConfig{
+		TrainingDataBytes:    engTrainedData,
+		Languages:            map[string][]byte{"first": engTrainedData, "second": engTrainedData},
+		LanguageMemoryBudget: 1,
+	})
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	_, err = pool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{Language: "klingon"})
+	if err == nil || !strings.Contains(err.Error(), "eng, first, second") {
+		t.Fatalf("Pool.ParseImage should have failed listing the available languages, got %v", err)
+	}
+
+	// Languages within Config.Tessdata are available too.
+	tessdataPool, err := gogosseract.NewPool(ctx, 1, gogosseract.PoolConfig{
+		Config: gogosseract.Config{
+			Tessdata: fstest.MapFS{
+				"eng.traineddata":   &fstest.MapFile{Data: engTrainedData},
+				"other.traineddata": &fstest.MapFile{Data: engTrainedData},
+			},
+		},
+	})
+	test.FailOnError(t, err)
+	defer tessdataPool.Close()
+	text, err := tessdataPool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{Language: "other"})
+	test.FailOnError(t, err)
+	if text != logoText {
+		t.Fatalf("Pool.ParseImage returned unexpected text %s", text)
+	}
+
+	// The single worker reloads with each language, and the tiny budget evicts it back to eng after every other language.
+	for _, lang := range []string{"", "first", "eng", "second", "first"} {
+		text, err := pool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{Language: lang})
+		test.FailOnError(t, err)
+		if text != logoText {
+			t.Fatalf("Pool.ParseImage in %s returned unexpected text %s", lang, text)
+		}
+	}
+
+	// A worker left idle with another language is replaced by a worker of Config.Language.
+	idlePool, err := gogosseract.NewPool(ctx, 1, gogosseract.PoolConfig{
+		TrainingDataBytes:   engTrainedData,
+		Languages:           map[string][]byte{"first": engTrainedData},
+		LanguageIdleTimeout: 10 * time.Millisecond,
+	})
+	test.FailOnError(t, err)
+	defer idlePool.Close()
+	// The worker keeps its ID when it reloads with another language.
+	reloaded := idlePool.MemoryUsage()[0].ID
+	_, err = idlePool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{Language: "first"})
+	test.FailOnError(t, err)
+	for usage := idlePool.MemoryUsage(); len(usage) != 1 || usage[0].ID == reloaded; usage = idlePool.MemoryUsage() {
+		if ctx.Err() != nil {
+			t.Fatalf("Pool didn't replace the idle worker, workers %+v", usage)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPool_TrainingDataReaderAt(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := gogosseract.NewPool(ctx, 1, gogosseract.PoolConfig{TrainingDataReaderAt: onlyReaderAt{bytes.NewReader(engTrainedData)}})
+	if err == nil {
+		t.Fatalf("gogosseract.NewPool should have failed without the io.ReaderAt's size")
+	}
+
+	pool, err := gogosseract.NewPool(ctx, 2, gogosseract.PoolConfig{
+		TrainingDataReaderAt: bytes.NewReader(engTrainedData),
+		RecycleAfterImages:   1,
+	})
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	for i := 0; i < 3; i++ {
+		text, err := pool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{})
+		test.FailOnError(t, err)
+		if text != logoText {
+			t.Fatalf("Pool.ParseImage returned unexpected text %s", text)
+		}
+	}
+
+	usage := pool.MemoryUsage()
+	if len(usage) == 0 {
+		t.Fatalf("Pool.MemoryUsage didn't report any workers")
+	}
+	for _, u := range usage {
+		if u.Model == 0 || u.Current < u.Model {
+			t.Fatalf("Pool.MemoryUsage returned unexpected %+v", u)
+		}
+	}
+}
+
+// onlyReaderAt hides every method besides ReadAt.
+type onlyReaderAt struct {
+	io.ReaderAt
+}
+
+func TestPool_ReloadModel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := gogosseract.NewPool(ctx, 2, gogosseract.PoolConfig{TrainingDataBytes: engTrainedData})
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	parse := func() {
+		t.Helper()
+		text, err := pool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{})
+		test.FailOnError(t, err)
+		if text != logoText {
+			t.Fatalf("Pool.ParseImage returned unexpected text %s", text)
+		}
+	}
+
+	if err := pool.ReloadModel(ctx, gogosseract.Config{Language: "fra", TrainingData: bytes.NewBuffer(engTrainedData)}); err == nil {
+		t.Fatalf("Pool.ReloadModel should have failed changing the language")
+	}
+
+	// Requests keep being served while the workers roll over.
+	done := make(chan error, 1)
+	go func() {
+		done <- pool.ReloadModel(ctx, gogosseract.Config{
+			Tessdata: fstest.MapFS{"eng.traineddata": &fstest.MapFile{Data: engTrainedData}},
+		})
+	}()
+	parse()
+	test.FailOnError(t, <-done)
+	parse()
+
+	// Broken training data rolls back, leaving the Pool working as before.
+	err = pool.ReloadModel(ctx, gogosseract.Config{TrainingData: strings.NewReader("not training data")})
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("Pool.ReloadModel should have rolled back, got %v", err)
+	}
+	parse()
+	if usage := pool.MemoryUsage(); len(usage) < 2 {
+		t.Fatalf("Pool.MemoryUsage reported %d workers instead of at least 2", len(usage))
+	}
+}
+
+func TestPool_DeduplicateRequests(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := gogosseract.NewPool(ctx, 1, gogosseract.PoolConfig{TrainingDataBytes: engTrainedData, DeduplicateRequests: true})
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	const count = 5
+	textChan := make(chan string, count)
+	for i := 0; i < count; i++ {
+		go func() {
+			// A reader that can't be read twice still works, since it gets buffered for hashing.
+			text, err := pool.ParseImage(ctx, io.MultiReader(bytes.NewReader(docsImg)), gogosseract.ParseImageOptions{})
+			if err != nil {
+				panic(err)
+			}
+			textChan <- text
+		}()
+	}
+	for i := 0; i < count; i++ {
+		if text := <-textChan; text != docsText {
+			t.Fatalf("Pool.ParseImage returned unexpected text %s", text)
+		}
+	}
+	// The first request keeps the only worker busy long enough for the rest to join it.
+	if stats := pool.Stats(); stats.Requests != count || stats.Recognition.Count >= count {
+		t.Fatalf("Pool.ParseImage wasn't deduplicated, got %+v", stats)
+	}
+}
+
+func TestPool_ResultCache(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cfg := gogosseract.PoolConfig{TrainingDataBytes: engTrainedData}
+	cfg.ResultCache = gogosseract.NewLRUCache(10)
+	pool, err := gogosseract.NewPool(ctx, 1, cfg)
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	for _, opts := range []gogosseract.ParseImageOptions{{}, {}, {IsHOCR: true}} {
+		text, err := pool.ParseImage(ctx, bytes.NewBuffer(logoImg), opts)
+		test.FailOnError(t, err)
+		if !opts.IsHOCR && text != logoText {
+			t.Fatalf("Pool.ParseImage returned unexpected text %s", text)
+		}
+	}
+	if stats := pool.Stats(); stats.CacheHits != 1 || stats.CacheMisses != 2 || stats.Recognition.Count != 2 {
+		t.Fatalf("Pool.ParseImage didn't skip the worker on a cache hit, got %+v", stats)
+	}
+}
+
+func TestPool_ShutdownDrains(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := gogosseract.NewPool(ctx, 1, gogosseract.PoolConfig{TrainingDataBytes: engTrainedData})
+	test.FailOnError(t, err)
+
+	jobs := []*gogosseract.Job{
+		pool.Submit(ctx, bytes.NewBuffer(docsImg), gogosseract.ParseImageOptions{}),
+		pool.Submit(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{}),
+	}
+	summary, err := pool.Shutdown(ctx)
+	test.FailOnError(t, err)
+	if summary.Aborted != 0 {
+		t.Fatalf("Pool.Shutdown aborted %d requests", summary.Aborted)
+	}
+	for _, j := range jobs {
+		if _, err := j.Result(); err != nil {
+			t.Fatalf("Job submitted before Pool.Shutdown failed due to %v", err)
+		}
+	}
+	if _, err := pool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{}); err == nil {
+		t.Fatalf("Pool.ParseImage should have failed after Pool.Shutdown")
+	}
+}
+
+func TestPool_Tenants(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := gogosseract.NewPool(ctx, 2, gogosseract.PoolConfig{
+		TrainingDataBytes: engTrainedData,
+		Tenants:           map[string]gogosseract.TenantConfig{"bulk": {MaxConcurrency: 1}},
+	})
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	const bulk = 4
+	errChan := make(chan error, bulk)
+	for i := 0; i < bulk; i++ {
+		go func() {
+			_, err := pool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{TenantID: "bulk"})
+			errChan <- err
+		}()
+	}
+	// bulk can only use one worker at a time, so the other one is free for everyone else.
+	text, err := pool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{})
+	test.FailOnError(t, err)
+	if text != logoText {
+		t.Fatalf("Pool.ParseImage returned unexpected text %s", text)
+	}
+	if running := pool.Stats().Tenants["bulk"].Running; running > 1 {
+		t.Fatalf("Pool.Stats counted %d running requests despite MaxConcurrency", running)
+	}
+	for i := 0; i < bulk; i++ {
+		test.FailOnError(t, <-errChan)
+	}
+
+	stats := pool.Stats().Tenants
+	if stats["bulk"].Handled != bulk || stats[""].Handled != 1 || stats["bulk"].QueueWait.Count != bulk {
+		t.Fatalf("Pool.Stats returned unexpected %+v", stats)
+	}
+}
+
+func TestPool_WorkerProcesses(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := gogosseract.NewPool(ctx, 2, gogosseract.PoolConfig{
+		TrainingDataBytes: engTrainedData,
+		WorkerProcesses:   &gogosseract.WorkerProcessConfig{},
+	})
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	var progressed bool
+	text, err := pool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{ProgressCB: func(int32) { progressed = true }})
+	test.FailOnError(t, err)
+	if text != logoText || !progressed {
+		t.Fatalf("Pool.ParseImage returned unexpected text %s, progressed %t", text, progressed)
+	}
+	if err := pool.Do(ctx, func(context.Context, *gogosseract.Tesseract) error { return nil }); err == nil {
+		t.Fatalf("Pool.Do succeeded without a Tesseract in this process")
+	}
+
+	// A canceled request fails without getting in the way of the next one.
+	interruptCtx, interrupt := context.WithCancel(ctx)
+	interrupt()
+	_, err = pool.ParseImage(interruptCtx, bytes.NewBuffer(docsImg), gogosseract.ParseImageOptions{})
+	if err == nil {
+		t.Fatalf("Pool.ParseImage succeeded despite its canceled context")
+	}
+	text, err = pool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{})
+	test.FailOnError(t, err)
+	if text != logoText {
+		t.Fatalf("Pool.ParseImage returned unexpected text %s", text)
+	}
+	if stats := pool.Stats(); len(stats.Workers) == 0 || stats.Workers[0].Model == 0 {
+		t.Fatalf("Pool.Stats returned unexpected %+v", stats)
+	}
+}
+
+func TestPool_WorkerProcessesIdleCrash(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var cmdsMu sync.Mutex
+	var cmds []*exec.Cmd
+	pool, err := gogosseract.NewPool(ctx, 1, gogosseract.PoolConfig{
+		TrainingDataBytes: engTrainedData,
+		WorkerProcesses: &gogosseract.WorkerProcessConfig{Command: func() *exec.Cmd {
+			exe, _ := os.Executable()
+			cmd := exec.Command(exe, os.Args[1:]...)
+			cmdsMu.Lock()
+			cmds = append(cmds, cmd)
+			cmdsMu.Unlock()
+			return cmd
+		}},
+	})
+	test.FailOnError(t, err)
+	defer pool.Close()
+
+	// A worker process killed while idle, like by the OOM killer, is replaced before the next request reaches it.
+	cmdsMu.Lock()
+	test.FailOnError(t, cmds[0].Process.Kill())
+	cmdsMu.Unlock()
+	for health := pool.Health(); health.Failed == 0 || health.Live == 0; health = pool.Health() {
+		if ctx.Err() != nil {
+			t.Fatalf("Pool didn't replace the killed worker process, health %+v", health)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	text, err := pool.ParseImage(ctx, bytes.NewBuffer(logoImg), gogosseract.ParseImageOptions{})
+	test.FailOnError(t, err)
+	if text != logoText {
+		t.Fatalf("Pool.ParseImage returned unexpected text %s", text)
+	}
+}
@@ -0,0 +1,438 @@
+package gogosseract
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"time"
+
+	"github.com/danlock/gogosseract/internal/wasm"
+	"github.com/danlock/pkg/errors"
+)
+
+// WorkerProcessConfig runs a Pool's workers within child processes, set with PoolConfig.WorkerProcesses.
+// By default each worker process re-executes the current binary, which must call RunWorkerProcess at the start of main.
+// A worker process that crashes is restarted, failing its request with ErrWorkerFailed, which PoolConfig.RetryPolicy can retry.
+// One that crashes while idle is restarted right away, and counted in Pool.Health.
+// Requests interrupted by their context kill the worker process too. Pool.Do isn't supported,
+// and Config.Stdout and Config.Stderr are replaced by the worker processes' stderr.
+type WorkerProcessConfig struct {
+	// MemoryLimit caps the WASM memory of each worker process in bytes. Requests that need more fail with ErrWorkerFailed,
+	// and their worker process is restarted. Zero leaves it up to WASM, which can't grow past 4GiB.
+	// It doesn't limit the rest of the worker process's memory, like its Go heap. Use the OS for that, such as cgroups,
+	// and a worker process it kills is restarted too.
+	MemoryLimit uint64
+	// Command creates a worker process, for running something besides the current binary with its arguments.
+	// It must call RunWorkerProcess, and leave Stdin and Stdout unset.
+	Command func() *exec.Cmd
+	// Stderr receives the stderr of every worker process. Defaults to os.Stderr.
+	Stderr io.Writer
+}
+
+const (
+	// workerProcessEnv is set in the environment of worker processes so RunWorkerProcess knows to serve.
+	workerProcessEnv = "GOGOSSERACT_WORKER_PROCESS"
+	// processExitTimeout is how long a worker process gets to exit on its own before it's killed.
+	processExitTimeout = 5 * time.Second
+)
+
+// RunWorkerProcess serves a Pool as one of its workers, if the Pool started this process for PoolConfig.WorkerProcesses.
+// The process exits once the Pool is done with it. Otherwise RunWorkerProcess returns right away,
+// so call it at the start of main, before doing anything a worker process shouldn't.
+func RunWorkerProcess() {
+	if os.Getenv(workerProcessEnv) == "" {
+		return
+	}
+	// stdout belongs to the protocol, so anything else printed goes to stderr instead.
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	if err := serveWorkerProcess(context.Background(), os.Stdin, out); err != nil {
+		fmt.Fprintf(os.Stderr, "gogosseract worker process %d failed due to %v\n", os.Getpid(), err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// The protocol between a Pool and its worker processes is a series of frames over the worker process's stdin and stdout.
+// Each frame is prefixed by its size as a big endian uint32. Messages are JSON frames, and images and training data are raw frames.
+// The Pool sends a processStart followed by the training data, and the worker process replies with a processResponse once it's ready.
+// Afterwards the Pool sends a processRequest followed by the image for every request, and the worker process replies
+// with any progress, then the final processResponse. Closing stdin tells the worker process to exit.
+
+// processStart configures a worker process's Tesseract.
+type processStart struct {
+	Language    string
+	Variables   map[string]string
+	MemoryLimit uint64
+}
+
+// processRequest is a request for a worker process, like a workerReq.
+type processRequest struct {
+	IsHOCR           bool
+	RemoveUnderlines bool
+	Variables        map[string]string
+	Layout           bool
+	// Progress asks for progress replies before the final one.
+	Progress bool
+}
+
+// processResponse is a worker process's reply, like a workerResp.
+type processResponse struct {
+	// Progress is set on progress replies, which carry nothing else.
+	Progress *int32 `json:",omitempty"`
+	Text     string
+	Rects    []image.Rectangle
+	Err      string
+	// Failed means the worker process's Tesseract is unusable, so it's exiting.
+	Failed bool
+	// Memory is the worker process's WASM memory in bytes.
+	Memory      uint64
+	ImageLoad   time.Duration
+	Recognition time.Duration
+}
+
+// writeFrame writes size bytes from r as a frame.
+func writeFrame(w io.Writer, size uint32, r io.Reader) error {
+	if err := binary.Write(w, binary.BigEndian, size); err != nil {
+		return errors.Errorf("binary.Write %w", err)
+	}
+	if _, err := io.CopyN(w, r, int64(size)); err != nil {
+		return errors.Errorf("io.CopyN %w", err)
+	}
+	return nil
+}
+
+// readFrame reads a whole frame. It returns io.EOF only if there was no frame left to read.
+func readFrame(r io.Reader) ([]byte, error) {
+	var size uint32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, errors.Errorf("binary.Read %w", err)
+	}
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, errors.Errorf("io.ReadFull %w", err)
+	}
+	return frame, nil
+}
+
+// writeMessage writes msg as a JSON frame.
+func writeMessage(w io.Writer, msg any) error {
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return errors.Errorf("json.Marshal %w", err)
+	}
+	return errors.Wrap(writeFrame(w, uint32(len(encoded)), bytes.NewReader(encoded)))
+}
+
+// readMessage reads a JSON frame into msg. It returns io.EOF only if there was no frame left to read.
+func readMessage(r io.Reader, msg any) error {
+	frame, err := readFrame(r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		return errors.Wrap(err)
+	}
+	if err := json.Unmarshal(frame, msg); err != nil {
+		return errors.Errorf("json.Unmarshal %w", err)
+	}
+	return nil
+}
+
+// processEngine is a worker's engine within a worker process.
+type processEngine struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// stdout is read through out, which the Pool closes once the worker process exits.
+	stdout *os.File
+	out    *bufio.Reader
+	// memory is the worker process's WASM memory as of its latest reply.
+	memory uint64
+	// exit is closed once the worker process exits, with waitErr holding how.
+	exit    chan struct{}
+	waitErr error
+	// done is set once the worker process is killed or has failed, so it's expected to exit.
+	done atomic.Bool
+}
+
+// startProcess starts a worker process and waits for it to load the training data.
+func startProcess(ctx context.Context, cfg WorkerProcessConfig, tessCfg Config, trainingData io.Reader) (*processEngine, error) {
+	size, err := wasm.GetReaderSize(ctx, &trainingData)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	var cmd *exec.Cmd
+	if cfg.Command != nil {
+		cmd = cfg.Command()
+	} else {
+		exe, err := os.Executable()
+		if err != nil {
+			return nil, errors.Errorf("os.Executable %w", err)
+		}
+		cmd = exec.Command(exe, os.Args[1:]...)
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, workerProcessEnv+"=1")
+	if cmd.Stderr == nil {
+		cmd.Stderr = cfg.Stderr
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.Errorf("cmd.StdinPipe %w", err)
+	}
+	// cmd.StdoutPipe would be closed as soon as the worker process exits, losing the reply it exited after sending.
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return nil, errors.Errorf("os.Pipe %w", err)
+	}
+	cmd.Stdout = stdoutWriter
+	err = cmd.Start()
+	stdoutWriter.Close()
+	if err != nil {
+		stdout.Close()
+		return nil, errors.Errorf("cmd.Start %w", err)
+	}
+
+	e := &processEngine{cmd: cmd, stdin: stdin, stdout: stdout, out: bufio.NewReader(stdout), exit: make(chan struct{})}
+	go func() {
+		e.waitErr = cmd.Wait()
+		close(e.exit)
+	}()
+	stop := context.AfterFunc(ctx, e.kill)
+	defer stop()
+
+	start := processStart{Language: tessCfg.Language, Variables: tessCfg.Variables, MemoryLimit: cfg.MemoryLimit}
+	var ready processResponse
+	err = writeMessage(stdin, start)
+	if err == nil {
+		err = writeFrame(stdin, size, trainingData)
+	}
+	if err == nil {
+		err = readMessage(e.out, &ready)
+	}
+	if err == nil && ready.Err != "" {
+		err = errors.Errorf("worker process failed to start due to %s", ready.Err)
+	} else if err != nil {
+		err = errors.Errorf("starting worker process %w", err)
+		if ctx.Err() != nil {
+			err = errors.Errorf("while starting worker process %w", errors.Join(context.Cause(ctx), err))
+		}
+	}
+	if err != nil {
+		e.kill()
+		return nil, errors.Join(err, e.Close(context.Background()))
+	}
+	e.memory = ready.Memory
+	return e, nil
+}
+
+// handle sends req to the worker process and waits for its reply, killing the worker process once ctx is done.
+func (e *processEngine) handle(ctx context.Context, req workerReq) workerResp {
+	if req.do != nil {
+		return workerResp{err: errors.Errorf("Pool.Do isn't supported with PoolConfig.WorkerProcesses")}
+	}
+	size, err := wasm.GetReaderSize(ctx, &req.img)
+	if err != nil {
+		return workerResp{err: errors.Wrap(err)}
+	}
+	stop := context.AfterFunc(ctx, e.kill)
+	defer stop()
+
+	msg := processRequest{
+		IsHOCR:           req.opts.IsHOCR,
+		RemoveUnderlines: req.opts.RemoveUnderlines,
+		Variables:        req.opts.Variables,
+		Layout:           req.layout,
+		Progress:         req.opts.ProgressCB != nil,
+	}
+	err = writeMessage(e.stdin, msg)
+	if err == nil {
+		err = writeFrame(e.stdin, size, req.img)
+	}
+	if err != nil {
+		// A partly sent request leaves the protocol out of sync, so the worker process has to go either way.
+		e.kill()
+		if ctx.Err() != nil {
+			return workerResp{err: errors.Errorf("interrupted due to %w", errors.Join(context.Cause(ctx), err))}
+		}
+		return workerResp{err: errors.Errorf("sending request to worker process %w", err)}
+	}
+
+	for {
+		var reply processResponse
+		if err := readMessage(e.out, &reply); err != nil {
+			e.kill()
+			if ctx.Err() != nil {
+				return workerResp{err: errors.Errorf("interrupted due to %w", errors.Join(context.Cause(ctx), err))}
+			}
+			<-e.exit
+			return workerResp{err: errors.Errorf("%w", ErrWorkerFailed{Err: errors.Errorf("worker process crashed %w", errors.Join(e.waitErr, err))})}
+		}
+		if reply.Progress != nil {
+			if req.opts.ProgressCB != nil {
+				req.opts.ProgressCB(*reply.Progress)
+			}
+			continue
+		}
+
+		e.memory = max(e.memory, reply.Memory)
+		resp := workerResp{str: reply.Text, rects: reply.Rects, imageLoad: reply.ImageLoad, recognition: reply.Recognition}
+		if reply.Err != "" {
+			resp.err = errors.Errorf("worker process %s", reply.Err)
+		}
+		if reply.Failed {
+			e.done.Store(true)
+			resp.err = errors.Errorf("%w", ErrWorkerFailed{Err: resp.err})
+		}
+		return resp
+	}
+}
+
+func (e *processEngine) memorySize() uint64 { return e.memory }
+
+func (e *processEngine) exited() <-chan struct{} { return e.exit }
+
+// isClosed reports whether the worker process is gone, or on its way out.
+func (e *processEngine) isClosed() bool {
+	select {
+	case <-e.exit:
+		return true
+	default:
+		return e.done.Load()
+	}
+}
+
+// kill ends the worker process right away.
+func (e *processEngine) kill() {
+	e.done.Store(true)
+	// Kill fails if the worker process already exited, which is fine.
+	_ = e.cmd.Process.Kill()
+}
+
+// Close asks the worker process to exit, killing it if it doesn't within processExitTimeout or before ctx is done.
+func (e *processEngine) Close(ctx context.Context) error {
+	select {
+	case <-e.exit:
+		// The worker process died while idle, which its worker already reported as a failure.
+		e.done.Store(true)
+	default:
+	}
+	// Closing stdin tells the worker process to exit.
+	e.stdin.Close()
+	timer := time.NewTimer(processExitTimeout)
+	defer timer.Stop()
+	select {
+	case <-e.exit:
+	case <-timer.C:
+		e.kill()
+	case <-ctx.Done():
+		e.kill()
+	}
+	<-e.exit
+	e.stdout.Close()
+	if e.done.Load() {
+		// The worker process exiting badly was expected, and already reported.
+		return nil
+	}
+	if e.waitErr != nil {
+		return errors.Errorf("worker process exited with %w", e.waitErr)
+	}
+	return nil
+}
+
+// serveWorkerProcess runs a worker process's side of the protocol, until in runs out or its Tesseract fails.
+func serveWorkerProcess(ctx context.Context, in io.Reader, out io.Writer) error {
+	reader := bufio.NewReader(in)
+	var start processStart
+	if err := readMessage(reader, &start); err != nil {
+		return errors.Wrap(err)
+	}
+	trainingData, err := readFrame(reader)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	tess, err := New(ctx, Config{
+		CompileConfig: wasm.CompileConfig{Stdout: os.Stderr, Stderr: os.Stderr},
+		Language:      start.Language,
+		TrainingData:  bytes.NewReader(trainingData),
+		Variables:     start.Variables,
+		memoryLimit:   start.MemoryLimit,
+	})
+	if err != nil {
+		return errors.Join(err, writeMessage(out, processResponse{Err: err.Error()}))
+	}
+	defer tess.Close(ctx)
+	trainingData = nil
+	if err := writeMessage(out, processResponse{Memory: tess.memorySize()}); err != nil {
+		return errors.Wrap(err)
+	}
+
+	for {
+		var msg processRequest
+		if err := readMessage(reader, &msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				// The Pool is done with us.
+				return nil
+			}
+			return errors.Wrap(err)
+		}
+		img, err := readFrame(reader)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+
+		req := workerReq{
+			ctx:    ctx,
+			img:    bytes.NewReader(img),
+			layout: msg.Layout,
+			opts: ParseImageOptions{
+				LoadImageOptions: LoadImageOptions{RemoveUnderlines: msg.RemoveUnderlines},
+				IsHOCR:           msg.IsHOCR,
+				Variables:        msg.Variables,
+			},
+		}
+		var progressErr error
+		if msg.Progress {
+			req.opts.ProgressCB = func(progress int32) {
+				if progressErr == nil {
+					progressErr = writeMessage(out, processResponse{Progress: &progress})
+				}
+			}
+		}
+		resp := tess.handle(ctx, req)
+		if progressErr != nil {
+			return errors.Wrap(progressErr)
+		}
+
+		reply := processResponse{Text: resp.str, Rects: resp.rects, ImageLoad: resp.imageLoad, Recognition: resp.recognition}
+		if resp.err != nil {
+			reply.Err = resp.err.Error()
+		}
+		reply.Failed = tess.isClosed()
+		if !reply.Failed {
+			reply.Memory = tess.memorySize()
+		}
+		if err := writeMessage(out, reply); err != nil {
+			return errors.Wrap(err)
+		}
+		if reply.Failed {
+			return errors.Errorf("Tesseract failed due to %w", resp.err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package gogosseract
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danlock/pkg/errors"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMain(m *testing.M) {
+	// Tests with PoolConfig.WorkerProcesses re-execute the test binary as their worker processes.
+	RunWorkerProcess()
+	os.Exit(m.Run())
+}
+
+func TestFrames(t *testing.T) {
+	var buf bytes.Buffer
+	progress := int32(50)
+	sent := processResponse{Progress: &progress, Text: "text", Memory: 1 << 20, Recognition: time.Second}
+	if err := writeMessage(&buf, sent); err != nil {
+		t.Fatalf("writeMessage failed due to %v", err)
+	}
+	if err := writeFrame(&buf, 5, strings.NewReader("image and then some")); err != nil {
+		t.Fatalf("writeFrame failed due to %v", err)
+	}
+
+	var got processResponse
+	if err := readMessage(&buf, &got); err != nil {
+		t.Fatalf("readMessage failed due to %v", err)
+	}
+	if diff := cmp.Diff(got, sent); diff != "" {
+		t.Fatalf(diff)
+	}
+	if frame, err := readFrame(&buf); err != nil || string(frame) != "image" {
+		t.Fatalf("readFrame returned %q due to %v", frame, err)
+	}
+	if err := readMessage(&buf, &got); err != io.EOF {
+		t.Fatalf("readMessage returned %v instead of io.EOF without any frames left", err)
+	}
+
+	// A frame cut short isn't mistaken for running out of frames.
+	writeFrame(&buf, 5, strings.NewReader("image"))
+	buf.Truncate(buf.Len() - 1)
+	if _, err := readFrame(&buf); err == nil || err == io.EOF {
+		t.Fatalf("readFrame returned %v for a truncated frame", err)
+	}
+}
+
+func TestServeWorkerProcess(t *testing.T) {
+	var in, out bytes.Buffer
+	writeMessage(&in, processStart{Language: "eng"})
+	writeFrame(&in, 17, strings.NewReader("not training data"))
+	if err := serveWorkerProcess(context.Background(), &in, &out); err == nil {
+		t.Fatalf("serveWorkerProcess succeeded with invalid training data")
+	}
+	var reply processResponse
+	if err := readMessage(&out, &reply); err != nil || !strings.Contains(reply.Err, "invalid training data") {
+		t.Fatalf("serveWorkerProcess replied %+v due to %v", reply, err)
+	}
+}
+
+func TestStartProcess(t *testing.T) {
+	ctx, cancel := context.WithTimeoutCause(context.Background(), 10*time.Second, errors.New("test timed out"))
+	defer cancel()
+
+	// The test binary is re-executed, and its TestMain serves as the worker process.
+	_, err := startProcess(ctx, WorkerProcessConfig{Stderr: io.Discard}, Config{Language: "eng"}, strings.NewReader("not training data"))
+	if err == nil || !strings.Contains(err.Error(), "invalid training data") {
+		t.Fatalf("startProcess didn't return the worker process's error, got %v", err)
+	}
+
+	_, err = NewPool(ctx, 2, PoolConfig{TrainingDataBytes: []byte("not training data"), WorkerProcesses: &WorkerProcessConfig{Stderr: io.Discard}})
+	if err == nil || !strings.Contains(err.Error(), "invalid training data") {
+		t.Fatalf("NewPool didn't return the worker process's error, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package gogosseract
+
+import (
+	"container/heap"
+)
+
+// requestQueue is a priority queue of requests waiting for an available worker.
+// Requests with a higher ParseImageOptions.Priority come first. Requests with equal priority are shared between tenants
+// by weighted fair queuing, and each tenant's requests are first in first out.
+type requestQueue struct {
+	reqs []queuedReq
+	// seq counts every pushed request to keep requests of equal priority in order.
+	seq uint64
+	// now is the virtual time weighted fair queuing is at, which is the finish time of the latest request handed out.
+	now float64
+	// untenanted stands in for the tenant of requests without one.
+	untenanted tenant
+}
+
+type queuedReq struct {
+	workerReq
+	seq uint64
+	// finish is the virtual time the request finishes at. Every request takes 1/TenantConfig.Weight,
+	// so tenants with a heavier weight get their requests handed out sooner.
+	finish float64
+}
+
+func (q *requestQueue) push(req workerReq) {
+	t := req.tenant
+	if t == nil {
+		t = &q.untenanted
+	}
+	// A tenant that's been idle starts from now, instead of catching up on the share it didn't use.
+	t.finish = max(q.now, t.finish) + 1/float64(max(1, t.cfg.Weight))
+	q.seq++
+	heap.Push(q, queuedReq{workerReq: req, seq: q.seq, finish: t.finish})
+}
+
+// peek returns the next request without removing it. The queue must not be empty.
+func (q *requestQueue) peek() workerReq {
+	return q.reqs[0].workerReq
+}
+
+// pop removes the next request. The queue must not be empty.
+func (q *requestQueue) pop() workerReq {
+	return q.remove(0)
+}
+
+// remove removes the request at index i to hand it out to a worker.
+func (q *requestQueue) remove(i int) workerReq {
+	req := heap.Remove(q, i).(queuedReq)
+	q.now = max(q.now, req.finish)
+	return req.workerReq
+}
+
+// prune drops the requests whose callers have already given up waiting.
+func (q *requestQueue) prune() {
+	live := q.reqs[:0]
+	for _, req := range q.reqs {
+		if req.ctx.Err() == nil {
+			live = append(live, req)
+		} else if req.tenant != nil {
+			req.tenant.queued.Add(-1)
+		}
+	}
+	clear(q.reqs[len(live):])
+	q.reqs = live
+	heap.Init(q)
+}
+
+// popFor removes the next request any of the idle workers can handle, returning it with the index of the worker to hand it to.
+// That's the first idle worker with the request's language loaded, or else the first one that can handle it at all.
+// A worker can't handle requests that avoid it, and nobody can handle requests of a tenant already at its TenantConfig.MaxConcurrency.
+func (q *requestQueue) popFor(idle []*worker) (workerReq, int, bool) {
+	workerFor := func(req queuedReq) int {
+		if !req.tenant.available() {
+			return -1
+		}
+		fallback := -1
+		for i, w := range idle {
+			if req.avoid == w {
+				continue
+			}
+			if w.language() == req.opts.Language {
+				return i
+			}
+			if fallback == -1 {
+				fallback = i
+			}
+		}
+		return fallback
+	}
+	if q.Len() > 0 {
+		if w := workerFor(q.reqs[0]); w != -1 {
+			return q.pop(), w, true
+		}
+	}
+	// Otherwise search the whole queue, since the next request they can handle could be anywhere within the heap.
+	next, nextWorker := -1, -1
+	for i, req := range q.reqs {
+		if next != -1 && !q.Less(i, next) {
+			continue
+		}
+		if w := workerFor(req); w != -1 {
+			next, nextWorker = i, w
+		}
+	}
+	if next == -1 {
+		return workerReq{}, -1, false
+	}
+	return q.remove(next), nextWorker, true
+}
+
+// Len, Less, Swap, Push and Pop implement heap.Interface. Use push and pop instead.
+
+func (q *requestQueue) Len() int { return len(q.reqs) }
+
+func (q *requestQueue) Less(i, j int) bool {
+	if q.reqs[i].opts.Priority != q.reqs[j].opts.Priority {
+		return q.reqs[i].opts.Priority > q.reqs[j].opts.Priority
+	}
+	if q.reqs[i].finish != q.reqs[j].finish {
+		return q.reqs[i].finish < q.reqs[j].finish
+	}
+	return q.reqs[i].seq < q.reqs[j].seq
+}
+
+func (q *requestQueue) Swap(i, j int) { q.reqs[i], q.reqs[j] = q.reqs[j], q.reqs[i] }
+
+func (q *requestQueue) Push(x any) {
+	req := x.(queuedReq)
+	if req.tenant != nil {
+		req.tenant.queued.Add(1)
+	}
+	q.reqs = append(q.reqs, req)
+}
+
+func (q *requestQueue) Pop() any {
+	last := q.reqs[len(q.reqs)-1]
+	if last.tenant != nil {
+		last.tenant.queued.Add(-1)
+	}
+	q.reqs[len(q.reqs)-1] = queuedReq{}
+	q.reqs = q.reqs[:len(q.reqs)-1]
+	return last
+}
@@ -0,0 +1,67 @@
+package gogosseract
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRequestQueue(t *testing.T) {
+	ctx := context.Background()
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	var queue requestQueue
+	for i, priority := range []int{0, 5, 0, -1, 5, 3} {
+		reqCtx := ctx
+		if i == 2 {
+			reqCtx = canceledCtx
+		}
+		queue.push(workerReq{ctx: reqCtx, opts: ParseImageOptions{Priority: priority, IsHOCR: i%2 == 0}})
+	}
+	queue.prune()
+
+	var got []int
+	for queue.Len() > 0 {
+		if queue.peek().opts.Priority != queue.reqs[0].opts.Priority {
+			t.Fatalf("requestQueue.peek() didn't return the next request")
+		}
+		got = append(got, queue.pop().opts.Priority)
+	}
+	if diff := cmp.Diff(got, []int{5, 5, 3, 0, -1}); diff != "" {
+		t.Fatalf(diff)
+	}
+
+	// Requests of equal priority come out in the order they went in.
+	for i := 0; i < 5; i++ {
+		queue.push(workerReq{ctx: ctx, opts: ParseImageOptions{Priority: 1, IsHOCR: i == 0}})
+	}
+	if !queue.pop().opts.IsHOCR {
+		t.Fatalf("requestQueue.pop() didn't return the first request")
+	}
+
+	// popFor skips the requests that avoid the worker.
+	w := &worker{}
+	queue.push(workerReq{ctx: ctx, opts: ParseImageOptions{Priority: 2}, avoid: w})
+	if req, _, ok := queue.popFor([]*worker{w}); !ok || req.opts.Priority != 1 || req.avoid == w {
+		t.Fatalf("requestQueue.popFor returned %+v", req)
+	}
+	if req, i, ok := queue.popFor([]*worker{w, {}}); !ok || req.opts.Priority != 2 || i != 1 {
+		t.Fatalf("requestQueue.popFor returned %+v for worker %d", req, i)
+	}
+	for queue.Len() > 0 {
+		queue.pop()
+	}
+
+	// Requests go to a worker with their language loaded, or else to the worker that waited longest.
+	eng, fra := &worker{model: &model{cfg: Config{Language: "eng"}}}, &worker{model: &model{cfg: Config{Language: "fra"}}}
+	queue.push(workerReq{ctx: ctx, opts: ParseImageOptions{Language: "fra", Priority: 1}})
+	queue.push(workerReq{ctx: ctx, opts: ParseImageOptions{Language: "deu"}})
+	if req, i, ok := queue.popFor([]*worker{eng, fra}); !ok || req.opts.Language != "fra" || i != 1 {
+		t.Fatalf("requestQueue.popFor returned %+v for worker %d", req, i)
+	}
+	if req, i, ok := queue.popFor([]*worker{eng, fra}); !ok || req.opts.Language != "deu" || i != 0 {
+		t.Fatalf("requestQueue.popFor returned %+v for worker %d", req, i)
+	}
+}
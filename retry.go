@@ -0,0 +1,125 @@
+package gogosseract
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+
+	"github.com/danlock/gogosseract/internal/wasm"
+	"github.com/danlock/pkg/errors"
+)
+
+// RetryPolicy retries requests that failed in a way another worker might not, like their worker crashing mid-request.
+// A retried request is never handed to the worker it just failed on, so it isn't retried when no other worker could take it.
+// Requests aren't retried once their context or the Pool's is done, and Pool.Do's fn is never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the most times a request is handed to a worker, counting the first. Zero or one disables retries.
+	MaxAttempts uint
+	// Retryable reports whether a request that failed with err should be tried again. Defaults to IsRetryable.
+	Retryable func(err error) bool
+	// Backoff is how long to wait before the first retry. It doubles for every retry after, up to MaxBackoff.
+	// Zero retries right away.
+	Backoff time.Duration
+	// MaxBackoff caps how long to wait between retries. Defaults to a minute.
+	MaxBackoff time.Duration
+}
+
+// ErrWorkerFailed is returned when a request's worker crashed, like from a WASM trap after running out of memory.
+// The worker is replaced, so the request could well succeed on another one. Check for it with errors.As.
+type ErrWorkerFailed struct {
+	Err error
+}
+
+func (e ErrWorkerFailed) Error() string {
+	return "worker failed with " + e.Err.Error()
+}
+
+func (e ErrWorkerFailed) Unwrap() error { return e.Err }
+
+// IsRetryable is the default RetryPolicy.Retryable. It retries requests that failed with ErrWorkerFailed.
+// ErrPoolSaturated isn't retried, since that's the Pool pushing back on callers under PoolConfig.MaxQueueDepth.
+func IsRetryable(err error) bool {
+	return errors.As(err, &ErrWorkerFailed{})
+}
+
+// retry queues req until a worker handles it, it fails in a way PoolConfig.RetryPolicy doesn't retry, or it runs out of attempts.
+func (p *Pool) retry(req workerReq) workerResp {
+	policy := p.cfg.RetryPolicy
+	rewind := req.rewind
+	if rewind == nil {
+		var err error
+		if rewind, err = rewindable(req.ctx, &req.img); err != nil {
+			// Let the request fail on its own.
+			return p.queueOnce(req)
+		}
+	}
+	delay := policy.Backoff
+	for attempt := uint(1); ; attempt++ {
+		var err error
+		if req.img, err = rewind(); err != nil {
+			return workerResp{err: errors.Errorf("rewinding the image for attempt %d %w", attempt, err)}
+		}
+		resp := p.queueOnce(req)
+		if resp.err == nil || attempt >= policy.MaxAttempts || req.ctx.Err() != nil || p.ctx.Err() != nil ||
+			!policy.Retryable(resp.err) || !p.canRetryElsewhere(resp.worker) {
+			return resp
+		}
+		p.metrics.retries.Add(1)
+		req.avoid = resp.worker
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.ctx.Done():
+			timer.Stop()
+			return workerResp{err: errors.Errorf("while backing off from %w", errors.Join(resp.err, context.Cause(req.ctx)))}
+		case <-p.ctx.Done():
+			timer.Stop()
+			return workerResp{err: errors.Errorf("while backing off from %w", errors.Join(resp.err, context.Cause(p.ctx)))}
+		case <-timer.C:
+		}
+		delay = min(2*delay, policy.MaxBackoff)
+	}
+}
+
+// canRetryElsewhere reports whether a worker besides failed could take a retried request.
+func (p *Pool) canRetryElsewhere(failed *worker) bool {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	if _, live := p.workers[failed]; failed == nil || !live || failed.retiring {
+		// The failed worker is gone or on its way out, so something else will take the request.
+		return true
+	}
+	return len(p.workers) > 1 || (p.isAutoscaling() && uint(len(p.workers))+p.starting < p.cfg.MaxWorkers)
+}
+
+// rewindable makes the rest of the reader readable again and again, returning it from the same spot every time rewind is called.
+// Call rewind before every read, including the first, since the original reader may have been used up.
+// GetReaderSize replaces any reader that can't tell its size with a buffered copy, which LoadImage would have to do anyway,
+// so it takes a pointer to the reader.
+func rewindable(ctx context.Context, readerPtr *io.Reader) (rewind func() (io.Reader, error), err error) {
+	if _, err := wasm.GetReaderSize(ctx, readerPtr); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	var img []byte
+	switch src := (*readerPtr).(type) {
+	case interface{ Bytes() []byte }:
+		img = src.Bytes()
+	case io.ReadSeeker:
+		offset, err := src.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, errors.Errorf("io.Seeker.Seek SeekCurrent %w", err)
+		}
+		return func() (io.Reader, error) {
+			if _, err := src.Seek(offset, io.SeekStart); err != nil {
+				return nil, errors.Errorf("io.Seeker.Seek SeekStart %w", err)
+			}
+			return src, nil
+		}, nil
+	default:
+		if img, err = io.ReadAll(src); err != nil {
+			return nil, errors.Errorf("io.ReadAll %w", err)
+		}
+	}
+	return func() (io.Reader, error) { return bytes.NewReader(img), nil }, nil
+}
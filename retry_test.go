@@ -0,0 +1,125 @@
+package gogosseract
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/danlock/pkg/errors"
+)
+
+func TestIsRetryable(t *testing.T) {
+	if !IsRetryable(errors.Errorf("parse %w", ErrWorkerFailed{Err: errors.New("wasm stack trace:")})) {
+		t.Fatalf("IsRetryable refused a retryable error")
+	}
+	if IsRetryable(errors.New("ocrEngine.LoadImage ocrErr=(failed to load image)")) || IsRetryable(context.Canceled) ||
+		IsRetryable(errors.Errorf("%w", ErrPoolSaturated{QueueDepth: 1})) {
+		t.Fatalf("IsRetryable allowed an error that shouldn't be retried")
+	}
+}
+
+func TestRewindable(t *testing.T) {
+	ctx := context.Background()
+	for name, img := range map[string]io.Reader{
+		"bytes.Buffer":   bytes.NewBufferString("image"),
+		"strings.Reader": strings.NewReader("image"),
+		"io.Reader":      iotest.HalfReader(strings.NewReader("image")),
+	} {
+		rewind, err := rewindable(ctx, &img)
+		if err != nil {
+			t.Fatalf("%s rewindable failed due to %v", name, err)
+		}
+		for attempt := 0; attempt < 3; attempt++ {
+			img, err := rewind()
+			if err != nil {
+				t.Fatalf("%s rewind failed due to %v", name, err)
+			}
+			if got, err := io.ReadAll(img); err != nil || string(got) != "image" {
+				t.Fatalf("%s attempt %d read %q due to %v", name, attempt, got, err)
+			}
+		}
+	}
+
+	// Seekers are rewound to where they were, not to their start.
+	seeker := strings.NewReader("not image")
+	seeker.Seek(4, io.SeekStart)
+	img := io.Reader(seeker)
+	rewind, err := rewindable(ctx, &img)
+	if err != nil {
+		t.Fatalf("rewindable failed due to %v", err)
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		img, err := rewind()
+		if err != nil {
+			t.Fatalf("rewind failed due to %v", err)
+		}
+		if got, _ := io.ReadAll(img); string(got) != "image" {
+			t.Fatalf("attempt %d read %q", attempt, got)
+		}
+	}
+}
+
+func TestPool_retry(t *testing.T) {
+	ctx, cancel := context.WithTimeoutCause(context.Background(), 10*time.Second, errors.New("test timed out"))
+	defer cancel()
+
+	first, second := &worker{reqs: make(chan workerReq, 1)}, &worker{reqs: make(chan workerReq, 1)}
+	p := &Pool{
+		cfg:        PoolConfig{RetryPolicy: RetryPolicy{MaxAttempts: 3, Retryable: IsRetryable, MaxBackoff: time.Second}},
+		submitChan: make(chan workerReq),
+		idleChan:   make(chan *worker),
+		leaveChan:  make(chan *worker),
+		workers:    map[*worker]struct{}{first: {}, second: {}},
+	}
+	p.ctx, p.shutdown = context.WithCancelCause(ctx)
+	defer p.wg.Wait()
+	defer p.shutdown(nil)
+	p.wg.Add(1)
+	go p.dispatch(p.ctx)
+
+	// serve stands in for a worker, responding to requests with respond.
+	serve := func(w *worker, respond func(img string) workerResp) {
+		for {
+			select {
+			case <-p.ctx.Done():
+				return
+			case p.idleChan <- w:
+			}
+			var req workerReq
+			select {
+			case <-p.ctx.Done():
+				return
+			case req = <-w.reqs:
+			}
+			img, _ := io.ReadAll(req.img)
+			resp := respond(string(img))
+			resp.worker = w
+			req.respChan <- resp
+		}
+	}
+	var failed bool
+	go serve(first, func(string) workerResp {
+		if failed {
+			t.Errorf("the retried request was handed to the worker it failed on")
+		}
+		failed = true
+		// The first worker is idle again by the time the second starts, so the retry has to skip it.
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			serve(second, func(img string) workerResp { return workerResp{str: img} })
+		}()
+		return workerResp{err: errors.Errorf("%w", ErrWorkerFailed{Err: errors.New("wasm stack trace:")})}
+	})
+
+	resp := p.retry(workerReq{ctx: ctx, img: iotest.OneByteReader(strings.NewReader("image"))})
+	if resp.err != nil || resp.str != "image" || resp.worker != second {
+		t.Fatalf("Pool.retry returned %+v", resp)
+	}
+	if retries := p.Stats().Retries; retries != 1 {
+		t.Fatalf("Pool.Stats counted %d retries", retries)
+	}
+}
@@ -0,0 +1,70 @@
+package gogosseract
+
+import (
+	"context"
+
+	"github.com/danlock/pkg/errors"
+)
+
+// ShutdownSummary reports on the requests Shutdown gave up on.
+type ShutdownSummary struct {
+	// Aborted counts the requests that were still unfinished when Shutdown's ctx ended. They fail instead.
+	// Each of ParseImages' images counts as a request.
+	Aborted uint
+	// Queued counts how many of the Aborted requests were still waiting for a worker.
+	Queued uint
+}
+
+// Shutdown gracefully closes the Pool. New requests are refused right away, while the requests already made
+// are given until ctx ends to finish. Then the Pool is closed like with Close, aborting any request left.
+// The returned error is a Join of close errors from every worker, if they exist.
+func (p *Pool) Shutdown(ctx context.Context) (ShutdownSummary, error) {
+	p.drainMu.Lock()
+	if p.drained == nil {
+		p.drained = make(chan struct{})
+		if p.outstanding == 0 {
+			close(p.drained)
+		}
+	}
+	drained := p.drained
+	p.drainMu.Unlock()
+
+	var summary ShutdownSummary
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		// Count before closing, since aborted requests finish quickly afterwards.
+		summary.Queued = p.queuedRequests()
+		p.drainMu.Lock()
+		summary.Aborted = p.outstanding
+		p.drainMu.Unlock()
+		p.shutdown(errors.Errorf("the Pool shut down before the request finished due to %w", context.Cause(ctx)))
+	}
+	return summary, errors.Wrap(p.close(true))
+}
+
+// track counts requests as outstanding until done is called once for each of them, so Shutdown can wait for them.
+// Once Shutdown is called, new requests are refused.
+func (p *Pool) track(requests uint) (done func(), err error) {
+	p.drainMu.Lock()
+	defer p.drainMu.Unlock()
+	if p.drained != nil {
+		err = errors.Errorf("the Pool is shutting down")
+		p.metrics.RequestDone(err)
+		return nil, err
+	}
+	p.outstanding += requests
+	return func() {
+		p.drainMu.Lock()
+		defer p.drainMu.Unlock()
+		p.outstanding--
+		if p.outstanding == 0 && p.drained != nil {
+			close(p.drained)
+		}
+	}, nil
+}
+
+// queuedRequests counts the requests waiting for a worker.
+func (p *Pool) queuedRequests() uint {
+	return uint(p.queueDepth.Load())
+}
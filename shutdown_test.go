@@ -0,0 +1,68 @@
+package gogosseract
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPool_Shutdown(t *testing.T) {
+	newPool := func() *Pool {
+		p := &Pool{}
+		p.ctx, p.shutdown = context.WithCancelCause(context.Background())
+		return p
+	}
+
+	// Shutdown waits for outstanding requests, and refuses new ones meanwhile.
+	p := newPool()
+	done, err := p.track(1)
+	if err != nil {
+		t.Fatalf("Pool.track failed due to %v", err)
+	}
+	result := make(chan ShutdownSummary, 1)
+	go func() {
+		summary, _ := p.Shutdown(context.Background())
+		result <- summary
+	}()
+	for {
+		extra, err := p.track(1)
+		if err != nil {
+			break
+		}
+		// Shutdown hasn't started yet, so finish that request right away.
+		extra()
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-result:
+		t.Fatalf("Pool.Shutdown returned before the outstanding request finished")
+	case <-time.After(10 * time.Millisecond):
+	}
+	done()
+	if summary := <-result; summary != (ShutdownSummary{}) {
+		t.Fatalf("Pool.Shutdown aborted requests after draining, got %+v", summary)
+	}
+
+	// Requests still outstanding once ctx ends are aborted.
+	p = newPool()
+	if _, err := p.track(1); err != nil {
+		t.Fatalf("Pool.track failed due to %v", err)
+	}
+	// A batch counts each of its requests, and only the finished ones stop counting.
+	batchDone, err := p.track(3)
+	if err != nil {
+		t.Fatalf("Pool.track failed due to %v", err)
+	}
+	batchDone()
+	batchDone()
+	p.queueDepth.Store(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	summary, err := p.Shutdown(ctx)
+	if err != nil || summary != (ShutdownSummary{Aborted: 2, Queued: 1}) {
+		t.Fatalf("Pool.Shutdown returned unexpected %+v, %v", summary, err)
+	}
+	if p.ctx.Err() == nil {
+		t.Fatalf("Pool.Shutdown didn't close the Pool")
+	}
+}
@@ -0,0 +1,139 @@
+package gogosseract
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// TenantConfig sets a tenant's share of the Pool's workers. Tenants are picked with ParseImageOptions.TenantID.
+type TenantConfig struct {
+	// Weight is the tenant's share of the workers compared to the other tenants with requests waiting. Defaults to 1.
+	// While both have requests waiting, a tenant with a Weight of 2 has twice as many handed to workers as a tenant with 1.
+	Weight uint
+	// MaxConcurrency caps how many of the tenant's requests the workers handle at once. Zero means no cap.
+	MaxConcurrency uint
+}
+
+// TenantStats is a snapshot of a tenant's requests.
+type TenantStats struct {
+	// Queued is how many of the tenant's requests are waiting for a worker, and Running is how many workers are handling.
+	Queued  uint
+	Running uint
+	// Handled counts the tenant's requests workers have finished, and Failed counts the ones that failed.
+	Handled uint64
+	Failed  uint64
+
+	QueueWait Histogram
+}
+
+// tenant keeps track of a tenant's requests.
+type tenant struct {
+	cfg     TenantConfig
+	queued  atomic.Int64
+	running atomic.Int64
+	handled atomic.Uint64
+	failed  atomic.Uint64
+	// queueWait is recorded by the workers.
+	queueWait histogram
+	// finish is the virtual time the tenant's latest queued request finishes at, for weighted fair queuing.
+	// Only the dispatcher uses it.
+	finish float64
+	// configured tenants are within PoolConfig.Tenants, and are never pruned.
+	configured bool
+	// refs counts the requests between tenants.get and tenants.put, guarded by tenants.mu.
+	refs int
+}
+
+// maxTenants is how many tenants are kept before the idle ones missing from PoolConfig.Tenants are pruned,
+// so callers passing arbitrary TenantIDs can't grow the Pool's memory without bound.
+const maxTenants = 1024
+
+// tenants holds the tenants that have made a request, by ParseImageOptions.TenantID.
+type tenants struct {
+	byID map[string]*tenant
+	mu   sync.Mutex
+}
+
+// get returns the tenant for id, creating it with its PoolConfig.Tenants entry if it's new.
+// Call put once the request is done with it.
+func (ts *tenants) get(id string, configs map[string]TenantConfig) *tenant {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if t, ok := ts.byID[id]; ok {
+		t.refs++
+		return t
+	}
+	if ts.byID == nil {
+		ts.byID = make(map[string]*tenant)
+	}
+	if len(ts.byID) >= maxTenants {
+		ts.pruneLocked()
+	}
+	cfg, configured := configs[id]
+	t := &tenant{cfg: cfg, configured: configured, refs: 1}
+	t.cfg.Weight = max(1, t.cfg.Weight)
+	ts.byID[id] = t
+	return t
+}
+
+// put releases a tenant from get.
+func (ts *tenants) put(t *tenant) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t.refs--
+}
+
+// pruneLocked forgets the tenants missing from PoolConfig.Tenants without any requests, along with their stats.
+// Any finish time they had is behind the queue's by now, so forgetting it doesn't change their share. ts.mu must be held.
+func (ts *tenants) pruneLocked() {
+	for id, t := range ts.byID {
+		if !t.configured && t.refs <= 0 && t.queued.Load() <= 0 && t.running.Load() <= 0 {
+			delete(ts.byID, id)
+		}
+	}
+}
+
+// stats snapshots every tenant.
+func (ts *tenants) stats() map[string]TenantStats {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	stats := make(map[string]TenantStats, len(ts.byID))
+	for id, t := range ts.byID {
+		stats[id] = TenantStats{
+			Queued:    uint(max(0, t.queued.Load())),
+			Running:   uint(max(0, t.running.Load())),
+			Handled:   t.handled.Load(),
+			Failed:    t.failed.Load(),
+			QueueWait: t.queueWait.snapshot(),
+		}
+	}
+	return stats
+}
+
+// available reports whether the tenant can have another request handed to a worker without going over TenantConfig.MaxConcurrency.
+// Only the dispatcher starts requests, so it stays true until the dispatcher calls start.
+func (t *tenant) available() bool {
+	return t == nil || t.cfg.MaxConcurrency == 0 || t.running.Load() < int64(t.cfg.MaxConcurrency)
+}
+
+// start counts a request handed to a worker.
+func (t *tenant) start() {
+	if t != nil {
+		t.running.Add(1)
+	}
+}
+
+// stop counts a request a worker is done with, or one handed back without being handled.
+func (t *tenant) stop(handled bool, err error) {
+	if t == nil {
+		return
+	}
+	t.running.Add(-1)
+	if !handled {
+		return
+	}
+	t.handled.Add(1)
+	if err != nil {
+		t.failed.Add(1)
+	}
+}
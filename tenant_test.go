@@ -0,0 +1,93 @@
+package gogosseract
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/danlock/pkg/errors"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRequestQueue_tenants(t *testing.T) {
+	ctx := context.Background()
+	var ts tenants
+	cfgs := map[string]TenantConfig{"heavy": {Weight: 2}, "capped": {MaxConcurrency: 1}}
+	heavy, light, capped := ts.get("heavy", cfgs), ts.get("light", cfgs), ts.get("capped", cfgs)
+	if light.cfg.Weight != 1 || ts.get("heavy", nil) != heavy {
+		t.Fatalf("tenants.get returned an unexpected tenant")
+	}
+
+	// The bulk of heavy's requests arrive first, yet light still gets a third of the workers.
+	var queue requestQueue
+	for i := 0; i < 6; i++ {
+		queue.push(workerReq{ctx: ctx, opts: ParseImageOptions{TenantID: "heavy"}, tenant: heavy})
+	}
+	for i := 0; i < 3; i++ {
+		queue.push(workerReq{ctx: ctx, opts: ParseImageOptions{TenantID: "light"}, tenant: light})
+	}
+	if stats := ts.stats(); stats["heavy"].Queued != 6 || stats["light"].Queued != 3 {
+		t.Fatalf("tenants.stats returned %+v", stats)
+	}
+	var got []string
+	for queue.Len() > 0 {
+		got = append(got, queue.pop().opts.TenantID)
+	}
+	if diff := cmp.Diff(got, []string{"heavy", "heavy", "light", "heavy", "heavy", "light", "heavy", "heavy", "light"}); diff != "" {
+		t.Fatalf(diff)
+	}
+
+	// A tenant that was idle doesn't get to catch up on the share it didn't use.
+	queue.push(workerReq{ctx: ctx, opts: ParseImageOptions{TenantID: "heavy"}, tenant: heavy})
+	queue.push(workerReq{ctx: ctx, opts: ParseImageOptions{TenantID: "fresh"}, tenant: ts.get("fresh", cfgs)})
+	if next := queue.peek().opts.TenantID; next != "heavy" {
+		t.Fatalf("requestQueue handed out %s's request first", next)
+	}
+	for queue.Len() > 0 {
+		queue.pop()
+	}
+
+	// Tenants at their MaxConcurrency are skipped until a request of theirs stops.
+	w := &worker{}
+	queue.push(workerReq{ctx: ctx, opts: ParseImageOptions{TenantID: "capped", Priority: 1}, tenant: capped})
+	queue.push(workerReq{ctx: ctx, opts: ParseImageOptions{TenantID: "capped", Priority: 1}, tenant: capped})
+	queue.push(workerReq{ctx: ctx, opts: ParseImageOptions{TenantID: "light"}, tenant: light})
+	for _, want := range []string{"capped", "light"} {
+		req, _, ok := queue.popFor([]*worker{w})
+		if !ok || req.opts.TenantID != want {
+			t.Fatalf("requestQueue.popFor returned %+v instead of %s's request", req, want)
+		}
+		req.tenant.start()
+	}
+	if req, _, ok := queue.popFor([]*worker{w}); ok {
+		t.Fatalf("requestQueue.popFor returned %+v despite capped's MaxConcurrency", req)
+	}
+	capped.stop(true, errors.New("failed"))
+	req, _, ok := queue.popFor([]*worker{w})
+	if !ok {
+		t.Fatalf("requestQueue.popFor didn't return capped's request after one stopped")
+	}
+	req.tenant.start()
+
+	stats := ts.stats()["capped"]
+	if stats.Queued != 0 || stats.Running != 1 || stats.Handled != 1 || stats.Failed != 1 {
+		t.Fatalf("tenants.stats returned %+v", stats)
+	}
+}
+
+func TestTenants_prune(t *testing.T) {
+	var ts tenants
+	cfgs := map[string]TenantConfig{"configured": {Weight: 2}}
+	configured, busy := ts.get("configured", cfgs), ts.get("busy", cfgs)
+	ts.put(configured)
+	for i := 0; len(ts.byID) < maxTenants; i++ {
+		ts.put(ts.get(fmt.Sprint("idle", i), cfgs))
+	}
+
+	// Going over maxTenants forgets the idle tenants, but not the configured or busy ones.
+	ts.put(ts.get("new", cfgs))
+	if len(ts.byID) != 3 || ts.byID["configured"] != configured || ts.byID["busy"] != busy || ts.byID["new"] == nil {
+		t.Fatalf("tenants.get pruned unexpectedly, leaving %d tenants", len(ts.byID))
+	}
+	ts.put(busy)
+}
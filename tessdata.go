@@ -0,0 +1,136 @@
+package gogosseract
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/danlock/gogosseract/internal/wasm"
+	"github.com/danlock/gogosseract/traineddata"
+	"github.com/danlock/pkg/errors"
+)
+
+// TessdataFromEnv returns the directory in the TESSDATA_PREFIX environment variable as an fs.FS for Config.Tessdata.
+// New and NewPool fall back to it when no training data is configured.
+func TessdataFromEnv() (fs.FS, error) {
+	dir := os.Getenv("TESSDATA_PREFIX")
+	if dir == "" {
+		return nil, errors.New("TESSDATA_PREFIX isn't set")
+	}
+	return os.DirFS(dir), nil
+}
+
+// tessdataOrEnv returns tessdata, or TESSDATA_PREFIX if tessdata is nil.
+func tessdataOrEnv(tessdata fs.FS) (fs.FS, error) {
+	if tessdata != nil {
+		return tessdata, nil
+	}
+	return TessdataFromEnv()
+}
+
+// trainingDataFile is the name of lang's training data file within a tessdata directory.
+func trainingDataFile(lang string) string {
+	return lang + ".traineddata"
+}
+
+// openTrainingData opens lang's training data within tessdata, listing the available languages if it's missing.
+// For a combined language like "eng+fra" that's the first language's, Tesseract loads the rest from tessdata itself.
+func openTrainingData(tessdata fs.FS, lang string) (fs.File, error) {
+	lang, _, _ = strings.Cut(lang, "+")
+	file, err := tessdata.Open(trainingDataFile(lang))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.Errorf("%s not found in tessdata, available languages are (%s)",
+			trainingDataFile(lang), strings.Join(tessdataLanguages(tessdata), ", "))
+	} else if err != nil {
+		return nil, errors.Errorf("tessdata.Open %w", err)
+	}
+	return file, nil
+}
+
+// checkCombinedLanguages ensures tessdata has every language after the first in a combined language like "eng+fra",
+// since Tesseract skips any it can't find without an error.
+func checkCombinedLanguages(tessdata fs.FS, lang string) error {
+	langs := strings.Split(lang, "+")
+	for _, other := range langs[1:] {
+		if _, err := fs.Stat(tessdata, trainingDataFile(other)); err != nil {
+			return errors.Errorf("%s combines %s, but %w", lang, other, err)
+		}
+	}
+	return nil
+}
+
+// tessdataLanguages lists the languages with a training data file within tessdata.
+func tessdataLanguages(tessdata fs.FS) []string {
+	files, _ := fs.Glob(tessdata, trainingDataFile("*"))
+	for i := range files {
+		files[i] = strings.TrimSuffix(files[i], trainingDataFile(""))
+	}
+	return files
+}
+
+// validateTrainingData inspects the training data before it's copied into WASM, where Tesseract's errors aren't very helpful.
+func validateTrainingData(ctx context.Context, readerPtr *io.Reader) error {
+	size, err := wasm.GetReaderSize(ctx, readerPtr)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	var readerAt io.ReaderAt
+	switch src := (*readerPtr).(type) {
+	case io.ReaderAt:
+		readerAt = src
+	case interface{ Bytes() []byte }:
+		readerAt = bytes.NewReader(src.Bytes())
+	case io.ReadSeeker:
+		readerAt = seekReaderAt{src}
+	default:
+		// Without copying the training data, there's no way to read it twice.
+		return nil
+	}
+
+	info, err := traineddata.Parse(readerAt, int64(size))
+	if err != nil {
+		return errors.Errorf("invalid training data %w", err)
+	}
+	// This build of Tesseract only includes the LSTM engine, so a legacy only model is useless.
+	if !info.HasLSTM() {
+		return errors.Errorf("training data is missing an LSTM model, the only engine supported. Got %s", info)
+	}
+	return nil
+}
+
+// readerAtSize returns the size of PoolConfig.TrainingDataReaderAt, using whichever method it has of finding out.
+func readerAtSize(readerAt io.ReaderAt) (size int64, err error) {
+	switch src := readerAt.(type) {
+	case interface{ Size() int64 }:
+		// This case covers bytes.Reader, strings.Reader and io.SectionReader
+		size = src.Size()
+	case interface{ Stat() (fs.FileInfo, error) }:
+		// This case covers os.File and fs.File's that are also an io.ReaderAt
+		info, err := src.Stat()
+		if err != nil {
+			return 0, errors.Errorf("Stat %w", err)
+		}
+		size = info.Size()
+	default:
+		return 0, errors.Errorf("can't tell the size of %T, wrap it with io.NewSectionReader", readerAt)
+	}
+	if size == 0 {
+		return 0, errors.Errorf("PoolConfig.TrainingDataReaderAt was empty")
+	}
+	return size, nil
+}
+
+// seekReaderAt implements io.ReaderAt for an io.ReadSeeker. It leaves the io.ReadSeeker at an arbitrary offset.
+type seekReaderAt struct {
+	io.ReadSeeker
+}
+
+func (s seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := s.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(s, p)
+}
@@ -0,0 +1,183 @@
+// Package traineddata inspects Tesseract's traineddata files without running Tesseract.
+// A traineddata file is a container of components, starting with a table of their offsets.
+// See https://github.com/tesseract-ocr/tesseract/blob/main/src/ccutil/tessdatamanager.h for the details.
+package traineddata
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"slices"
+	"strings"
+
+	"github.com/danlock/pkg/errors"
+)
+
+// Component is one of the parts of a traineddata file, numbered like Tesseract's TessdataType.
+type Component int
+
+const (
+	LangConfig Component = iota
+	Unicharset
+	UnicharAmbigs
+	IntTemp
+	PffmTable
+	NormProto
+	PuncDawg
+	SystemDawg
+	NumberDawg
+	FreqDawg
+	FixedLengthDawgs
+	CubeUnicharset
+	CubeSystemDawg
+	ShapeTable
+	BigramDawg
+	UnambigDawg
+	ParamsModel
+	LSTM
+	LSTMPuncDawg
+	LSTMSystemDawg
+	LSTMNumberDawg
+	LSTMUnicharset
+	LSTMRecoder
+	Version
+)
+
+var componentNames = [...]string{
+	"config", "unicharset", "unicharambigs", "inttemp", "pffmtable", "normproto", "punc-dawg", "word-dawg",
+	"number-dawg", "freq-dawg", "fixed-length-dawgs", "cube-unicharset", "cube-word-dawg", "shapetable",
+	"bigram-dawg", "unambig-dawg", "params-model", "lstm", "lstm-punc-dawg", "lstm-word-dawg",
+	"lstm-number-dawg", "lstm-unicharset", "lstm-recoder", "version",
+}
+
+// String returns the component's file extension, as used by Tesseract's combine_tessdata tool.
+func (c Component) String() string {
+	if c < 0 || int(c) >= len(componentNames) {
+		return fmt.Sprintf("unknown(%d)", int(c))
+	}
+	return componentNames[c]
+}
+
+// maxEntries is Tesseract's kMaxNumTessdataEntries, used to tell the file's endianness apart.
+const maxEntries = 1000
+
+// ComponentInfo describes where a component lives within the traineddata file.
+type ComponentInfo struct {
+	Component Component
+	Offset    int64
+	Size      int64
+}
+
+// Info describes a traineddata file.
+type Info struct {
+	// Components lists every component within the file, in the order they're stored.
+	Components []ComponentInfo
+	// Version is the file's version string, or "Pre-4.0.0" for files too old to have one.
+	// Files from Tesseract 4 onwards look like "4.00.00alpha:eng:synth20170629:[1,36,0,1Ct3,3,16Mp3,3Lfys64Lfx96Lrx96Lfx512O1c1]".
+	Version string
+}
+
+// Has reports whether the file contains c.
+func (i *Info) Has(c Component) bool {
+	return slices.ContainsFunc(i.Components, func(info ComponentInfo) bool { return info.Component == c })
+}
+
+// HasLSTM reports whether the file contains an LSTM neural network model.
+func (i *Info) HasLSTM() bool {
+	return i.Has(LSTM)
+}
+
+// HasLegacy reports whether the file contains a model for the legacy Tesseract engine.
+func (i *Info) HasLegacy() bool {
+	return i.Has(IntTemp)
+}
+
+// IsLSTMOnly reports whether the file contains an LSTM model and no legacy model, like the files from tessdata_fast and tessdata_best.
+func (i *Info) IsLSTMOnly() bool {
+	return i.HasLSTM() && !i.HasLegacy()
+}
+
+// String lists the file's version and components.
+func (i *Info) String() string {
+	names := make([]string, len(i.Components))
+	for j, c := range i.Components {
+		names[j] = c.Component.String()
+	}
+	return fmt.Sprintf("traineddata %s with components (%s)", i.Version, strings.Join(names, ", "))
+}
+
+// Parse reads the offset table of a traineddata file of the given size, and its version string.
+// It returns an error if the file isn't a valid traineddata file.
+func Parse(r io.ReaderAt, size int64) (*Info, error) {
+	var header [4]byte
+	if err := readAt(r, header[:], 0); err != nil {
+		return nil, errors.Errorf("reading entry count %w", err)
+	}
+	// Tesseract writes the file in the machine's byte order, and detects a swapped file by the entry count being too large.
+	order := binary.ByteOrder(binary.LittleEndian)
+	entries := order.Uint32(header[:])
+	if entries > maxEntries {
+		order, entries = binary.BigEndian, bits.ReverseBytes32(entries)
+	}
+	if entries == 0 || entries > maxEntries {
+		return nil, errors.Errorf("invalid entry count %d", entries)
+	}
+
+	tableEnd := int64(len(header)) + 8*int64(entries)
+	if tableEnd > size {
+		return nil, errors.Errorf("offset table of %d entries is larger than the file (%d bytes)", entries, size)
+	}
+	table := make([]byte, tableEnd-int64(len(header)))
+	if err := readAt(r, table, int64(len(header))); err != nil {
+		return nil, errors.Errorf("reading offset table %w", err)
+	}
+	offsets := make([]int64, entries)
+	for i := range offsets {
+		offsets[i] = int64(order.Uint64(table[8*i:]))
+	}
+
+	info := &Info{}
+	for i, offset := range offsets {
+		if offset == -1 {
+			continue
+		}
+		// Each component runs until the next one starts, or until the end of the file.
+		end := size
+		for _, next := range offsets[i+1:] {
+			if next != -1 {
+				end = next
+				break
+			}
+		}
+		if offset < tableEnd || offset > end || end > size {
+			return nil, errors.Errorf("component %s has invalid offset %d", Component(i), offset)
+		}
+		info.Components = append(info.Components, ComponentInfo{Component: Component(i), Offset: offset, Size: end - offset})
+	}
+
+	info.Version = "Pre-4.0.0"
+	for _, c := range info.Components {
+		if c.Component != Version || c.Size == 0 {
+			continue
+		}
+		version := make([]byte, c.Size)
+		if err := readAt(r, version, c.Offset); err != nil {
+			return nil, errors.Errorf("reading version %w", err)
+		}
+		info.Version = string(version)
+	}
+	return info, nil
+}
+
+// readAt fills buf from r at off. io.ReaderAt's are allowed to return io.EOF alongside a full read at the end of the file.
+func readAt(r io.ReaderAt, buf []byte, off int64) error {
+	n, err := r.ReadAt(buf, off)
+	if n == len(buf) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
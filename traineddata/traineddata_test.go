@@ -0,0 +1,141 @@
+package traineddata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// build assembles a traineddata file from components, written in the given byte order.
+func build(order binary.ByteOrder, entries int, components map[Component]string) []byte {
+	offsets := make([]int64, entries)
+	var data bytes.Buffer
+	tableEnd := int64(4 + 8*entries)
+	for i := range offsets {
+		content, ok := components[Component(i)]
+		if !ok {
+			offsets[i] = -1
+			continue
+		}
+		offsets[i] = tableEnd + int64(data.Len())
+		data.WriteString(content)
+	}
+
+	var file bytes.Buffer
+	_ = binary.Write(&file, order, uint32(entries))
+	_ = binary.Write(&file, order, offsets)
+	file.Write(data.Bytes())
+	return file.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	lstmOnly := map[Component]string{LSTM: "lstm", LSTMUnicharset: "chars", LSTMRecoder: "recoder", Version: "4.1.0:eng"}
+	tests := []struct {
+		name         string
+		file         []byte
+		wantErr      bool
+		wantVersion  string
+		wantLSTMOnly bool
+		wantLegacy   bool
+		wantSizes    map[Component]int64
+	}{
+		{
+			"lstm only",
+			build(binary.LittleEndian, 24, lstmOnly),
+			false,
+			"4.1.0:eng",
+			true,
+			false,
+			map[Component]int64{LSTM: 4, LSTMUnicharset: 5, LSTMRecoder: 7, Version: 9},
+		},
+		{
+			"big endian",
+			build(binary.BigEndian, 24, lstmOnly),
+			false,
+			"4.1.0:eng",
+			true,
+			false,
+			map[Component]int64{LSTM: 4, LSTMUnicharset: 5, LSTMRecoder: 7, Version: 9},
+		},
+		{
+			"legacy and lstm",
+			build(binary.LittleEndian, 24, map[Component]string{Unicharset: "u", IntTemp: "it", LSTM: "lstm", Version: "4.0.0"}),
+			false,
+			"4.0.0",
+			false,
+			true,
+			map[Component]int64{Unicharset: 1, IntTemp: 2, LSTM: 4, Version: 5},
+		},
+		{
+			"pre 4.0.0",
+			build(binary.LittleEndian, 17, map[Component]string{Unicharset: "u", IntTemp: "it"}),
+			false,
+			"Pre-4.0.0",
+			false,
+			true,
+			map[Component]int64{Unicharset: 1, IntTemp: 2},
+		},
+		{
+			"empty",
+			nil,
+			true,
+			"", false, false, nil,
+		},
+		{
+			"too many entries",
+			build(binary.LittleEndian, 1001, lstmOnly),
+			true,
+			"", false, false, nil,
+		},
+		{
+			"truncated offset table",
+			build(binary.LittleEndian, 24, lstmOnly)[:50],
+			true,
+			"", false, false, nil,
+		},
+		{
+			"truncated components",
+			build(binary.LittleEndian, 24, lstmOnly)[:200],
+			true,
+			"", false, false, nil,
+		},
+		{
+			"not a traineddata file",
+			[]byte("<html>404 not found</html>"),
+			true,
+			"", false, false, nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Parse(bytes.NewReader(tt.file), int64(len(tt.file)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if info.Version != tt.wantVersion {
+				t.Fatalf("Parse() version = %s, want %s", info.Version, tt.wantVersion)
+			}
+			if info.IsLSTMOnly() != tt.wantLSTMOnly || info.HasLegacy() != tt.wantLegacy {
+				t.Fatalf("Parse() %s IsLSTMOnly = %v HasLegacy = %v", info, info.IsLSTMOnly(), info.HasLegacy())
+			}
+			sizes := make(map[Component]int64, len(info.Components))
+			for _, c := range info.Components {
+				sizes[c.Component] = c.Size
+			}
+			if diff := cmp.Diff(sizes, tt.wantSizes); diff != "" {
+				t.Fatalf(diff)
+			}
+		})
+	}
+}
+
+func TestComponent_String(t *testing.T) {
+	if LSTM.String() != "lstm" || Version.String() != "version" || Component(30).String() != "unknown(30)" {
+		t.Fatalf("Component.String() returned unexpected names")
+	}
+}